@@ -0,0 +1,194 @@
+package binomial
+
+import "cmp"
+
+// Binomial heap: O(log n) Insert, Meld, DeleteMin & DecreaseKey.
+
+// A handle on an inserted element, for DecreaseKey. It is only
+// valid until its element is deleted.
+type Node[T any] struct {
+    value T
+    tree *tree[T] // where the element currently is
+}
+
+func (n *Node[T]) Value() T {
+    return n.value
+}
+
+// DecreaseKey moves elements up the tree, rather than trees, so
+// handles point at elements and trees point back at them.
+type tree[T any] struct {
+    item *Node[T]
+    parent *tree[T]
+    child *tree[T] // of the highest degree
+    sibling *tree[T] // next root, or next child of lower degree
+    degree int
+}
+
+type Heap[T any] struct {
+    head *tree[T] // roots, by increasing degree
+    size int
+    less func(a, b T) bool
+}
+
+func New[T any](less func(a, b T) bool) *Heap[T] {
+    return &Heap[T]{less: less}
+}
+
+func NewMin[T cmp.Ordered]() *Heap[T] {
+    return New(cmp.Less[T])
+}
+
+func (h *Heap[T]) Len() int {
+    return h.size
+}
+
+func (h *Heap[T]) Insert(x T) *Node[T] {
+    n := &Node[T]{value: x}
+    n.tree = &tree[T]{item: n}
+    h.head = h.union(h.head, n.tree)
+    h.size++
+    return n
+}
+
+func (h *Heap[T]) Push(x T) {
+    h.Insert(x)
+}
+
+func (h *Heap[T]) Peek() (T, bool) {
+    if h.head == nil {
+        var zero T
+        return zero, false
+    }
+    _, min := h.minRoot()
+    return min.item.value, true
+}
+
+// DeleteMin removes and returns the least element. It panics if h is empty.
+func (h *Heap[T]) DeleteMin() T {
+    prev, min := h.minRoot()
+    if prev == nil {
+        h.head = min.sibling
+    } else {
+        prev.sibling = min.sibling
+    }
+
+    // The children, by increasing degree, are a heap of their own.
+    var children *tree[T]
+    for c := min.child; c != nil; {
+        next := c.sibling
+        c.sibling = children
+        c.parent = nil
+        children = c
+        c = next
+    }
+    h.head = h.union(h.head, children)
+    h.size--
+
+    n := min.item
+    n.tree = nil
+    return n.value
+}
+
+func (h *Heap[T]) Pop() T {
+    return h.DeleteMin()
+}
+
+// DecreaseKey lowers the value of n, which must be in h, to x.
+// It panics if x is greater than the current value.
+func (h *Heap[T]) DecreaseKey(n *Node[T], x T) {
+    if h.less(n.value, x) {
+        panic("binomial: DecreaseKey to a greater value")
+    }
+    n.value = x
+    t := n.tree
+    for t.parent != nil && h.less(t.item.value, t.parent.item.value) {
+        p := t.parent
+        t.item, p.item = p.item, t.item
+        t.item.tree = t
+        p.item.tree = p
+        t = p
+    }
+}
+
+// Meld moves all elements of other, which must be ordered the
+// same way, into h, leaving other empty.
+func (h *Heap[T]) Meld(other *Heap[T]) {
+    if other == h {
+        return
+    }
+    h.head = h.union(h.head, other.head)
+    h.size += other.size
+    other.head, other.size = nil, 0
+}
+
+// The root with the least element, and the root before it.
+func (h *Heap[T]) minRoot() (*tree[T], *tree[T]) {
+    var prev *tree[T]
+    min := h.head
+    for t := h.head; t.sibling != nil; t = t.sibling {
+        if h.less(t.sibling.item.value, min.item.value) {
+            prev = t
+            min = t.sibling
+        }
+    }
+    return prev, min
+}
+
+// Merge two root lists by degree, then link roots of equal
+// degree, like adding two binary numbers.
+func (h *Heap[T]) union(a, b *tree[T]) *tree[T] {
+    head := merge(a, b)
+    if head == nil {
+        return nil
+    }
+    var prev *tree[T]
+    x := head
+    for next := x.sibling; next != nil; next = x.sibling {
+        if x.degree != next.degree || (next.sibling != nil && next.sibling.degree == x.degree) {
+            prev = x
+            x = next
+        } else if !h.less(next.item.value, x.item.value) {
+            x.sibling = next.sibling
+            link(next, x)
+        } else {
+            if prev == nil {
+                head = next
+            } else {
+                prev.sibling = next
+            }
+            link(x, next)
+            x = next
+        }
+    }
+    return head
+}
+
+func merge[T any](a, b *tree[T]) *tree[T] {
+    var head tree[T]
+    tail := &head
+    for a != nil && b != nil {
+        if a.degree <= b.degree {
+            tail.sibling = a
+            a = a.sibling
+        } else {
+            tail.sibling = b
+            b = b.sibling
+        }
+        tail = tail.sibling
+    }
+    if a != nil {
+        tail.sibling = a
+    } else {
+        tail.sibling = b
+    }
+    return head.sibling
+}
+
+// link makes root y, of the same degree as root z, the first child of z.
+func link[T any](y, z *tree[T]) {
+    y.parent = z
+    y.sibling = z.child
+    z.child = y
+    z.degree++
+}
@@ -0,0 +1,345 @@
+package heap
+
+import (
+    "context"
+    "errors"
+    "math/bits"
+    "runtime"
+    "sync"
+    "sync/atomic"
+)
+
+// Concurrent priority queue, safe for use by many goroutines
+//
+// A binary heap with a lock on every node, after Hunt, Michael,
+// Parthasarathy & Scott, "An efficient algorithm for concurrent
+// priority queue heaps" (1996). The global lock only guards the size,
+// so a push and a pop hold it just long enough to claim a slot; the
+// sifting that follows locks two or three nodes at a time, top-down,
+// and pushes bubble up while pops sift down in other parts of the heap.
+//
+// A node is EMPTY, AVAILABLE, or tagged with the id of the push still
+// bubbling it up. A pop may move such an element, and its push then
+// follows it up the tree, or finds it has been moved past its goal.
+
+var ErrClosed = errors.New("heap: queue closed")
+var ErrFull = errors.New("heap: queue full")
+
+const (
+    tagEmpty = 0
+    tagAvailable = 1
+)
+
+type cnode[T any] struct {
+    mu sync.Mutex
+    tag uint64
+    item T
+}
+
+// Pops wait for an element and, if the queue has a capacity, pushes
+// wait for room, until their context is done or the queue is closed.
+// After Close, pushes fail and pops drain what is left.
+type Concurrent[T any] struct {
+    less func(a, b T) bool
+
+    mu sync.Mutex // guards the fields below; never held while sifting
+    size int
+    capacity int
+    closed bool
+    popWaiters int
+    pushWaiters int
+    popReady chan struct{} // closed when a waiting pop may succeed
+    pushReady chan struct{} // closed when a waiting push may succeed
+
+    // Level l holds nodes 1 << l .. 1 << (l+1) - 1, counting the root
+    // as 1. Levels are added, never moved, so a node can be reached
+    // without the global lock.
+    levels [64]atomic.Pointer[[]cnode[T]]
+    allocated atomic.Int64 // nodes in the levels so far
+    ops atomic.Uint64 // ids for pushes, above tagAvailable
+}
+
+// NewConcurrent returns a queue holding at most capacity elements,
+// or any number if capacity is 0.
+func NewConcurrent[T any](less func(a, b T) bool, capacity int) *Concurrent[T] {
+    if capacity < 0 {
+        panic("heap: negative capacity")
+    }
+    q := &Concurrent[T]{
+        less: less,
+        capacity: capacity,
+        popReady: make(chan struct{}),
+        pushReady: make(chan struct{}),
+    }
+    q.ops.Store(tagAvailable)
+    return q
+}
+
+func (q *Concurrent[T]) node(i int) *cnode[T] {
+    level := bits.Len(uint(i)) - 1
+    return &(*q.levels[level].Load())[i - 1 << level]
+}
+
+// The node holding the n'th element, counting from 1. Each level is
+// filled in bit-reversed order, so that consecutive pushes bubble up
+// through different subtrees instead of queueing on one path; the
+// left children of a level still fill before the right ones.
+func slot(n int) int {
+    level := bits.Len(uint(n)) - 1
+    if level == 0 {
+        return n
+    }
+    offset := n - 1 << level
+    return 1 << level | int(bits.Reverse(uint(offset)) >> (bits.UintSize - level))
+}
+
+func (q *Concurrent[T]) full() bool {
+    return q.capacity > 0 && q.size >= q.capacity
+}
+
+// Waiters wait on a channel rather than a sync.Cond, so that they
+// can give up when their context is done.
+
+// Wake the pops waiting for an element, if any.
+func (q *Concurrent[T]) wakePops() {
+    if q.popWaiters > 0 {
+        close(q.popReady)
+        q.popReady = make(chan struct{})
+    }
+}
+
+func (q *Concurrent[T]) wakePushes() {
+    if q.pushWaiters > 0 {
+        close(q.pushReady)
+        q.pushReady = make(chan struct{})
+    }
+}
+
+// Push adds x, waiting while the queue is full. It returns ErrClosed
+// if the queue is or becomes closed, or the context's error.
+func (q *Concurrent[T]) Push(ctx context.Context, x T) error {
+    return q.push(ctx, x)
+}
+
+// TryPush adds x without waiting, or returns ErrFull or ErrClosed.
+func (q *Concurrent[T]) TryPush(x T) error {
+    return q.push(nil, x)
+}
+
+// A nil ctx means don't wait.
+func (q *Concurrent[T]) push(ctx context.Context, x T) error {
+    q.mu.Lock()
+    for !q.closed && q.full() {
+        if ctx == nil {
+            q.mu.Unlock()
+            return ErrFull
+        }
+        ready := q.pushReady
+        q.pushWaiters++
+        q.mu.Unlock()
+        select {
+        case <-ready:
+        case <-ctx.Done():
+            q.mu.Lock()
+            q.pushWaiters--
+            q.mu.Unlock()
+            return ctx.Err()
+        }
+        q.mu.Lock()
+        q.pushWaiters--
+    }
+    if q.closed {
+        q.mu.Unlock()
+        return ErrClosed
+    }
+
+    // Claim the next slot, and lock it before anyone can pop it.
+    q.size++
+    i := slot(q.size)
+    if int64(i) > q.allocated.Load() {
+        level := bits.Len(uint(i)) - 1
+        nodes := make([]cnode[T], 1 << level)
+        q.levels[level].Store(&nodes)
+        q.allocated.Store(1 << (level + 1) - 1)
+    }
+    n := q.node(i)
+    n.mu.Lock()
+    q.wakePops()
+    q.mu.Unlock()
+
+    op := q.ops.Add(1)
+    n.item = x
+    n.tag = op
+    n.mu.Unlock()
+    q.bubbleUp(i, op)
+    return nil
+}
+
+func (q *Concurrent[T]) bubbleUp(i int, op uint64) {
+    for i > 1 {
+        parent := i / 2
+        p, n := q.node(parent), q.node(i)
+        p.mu.Lock()
+        n.mu.Lock()
+        was := i
+        switch {
+        case p.tag == tagAvailable && n.tag == op:
+            if q.less(n.item, p.item) {
+                p.item, n.item = n.item, p.item
+                p.tag, n.tag = n.tag, p.tag
+                i = parent
+            } else {
+                n.tag = tagAvailable
+                i = 0
+            }
+        case p.tag == tagEmpty:
+            // A pop took the element to the root, and is done with it.
+            i = 0
+        case n.tag != op:
+            // A pop moved the element up.
+            i = parent
+        }
+        n.mu.Unlock()
+        p.mu.Unlock()
+        if i == was {
+            // The parent is still being pushed; let it move first.
+            runtime.Gosched()
+        }
+    }
+    if i == 1 {
+        root := q.node(1)
+        root.mu.Lock()
+        if root.tag == op {
+            root.tag = tagAvailable
+        }
+        root.mu.Unlock()
+    }
+}
+
+// PopWait removes and returns the top element, waiting while the
+// queue is empty. It returns ErrClosed once the queue is closed and
+// empty, or the context's error.
+func (q *Concurrent[T]) PopWait(ctx context.Context) (T, error) {
+    return q.pop(ctx)
+}
+
+// TryPop removes and returns the top element, and false if the queue is empty.
+func (q *Concurrent[T]) TryPop() (T, bool) {
+    x, err := q.pop(nil)
+    return x, err == nil
+}
+
+// A nil ctx means don't wait.
+func (q *Concurrent[T]) pop(ctx context.Context) (T, error) {
+    var zero T
+    q.mu.Lock()
+    for !q.closed && q.size == 0 {
+        if ctx == nil {
+            q.mu.Unlock()
+            return zero, ErrClosed
+        }
+        ready := q.popReady
+        q.popWaiters++
+        q.mu.Unlock()
+        select {
+        case <-ready:
+        case <-ctx.Done():
+            q.mu.Lock()
+            q.popWaiters--
+            q.mu.Unlock()
+            return zero, ctx.Err()
+        }
+        q.mu.Lock()
+        q.popWaiters--
+    }
+    if q.size == 0 {
+        q.mu.Unlock()
+        return zero, ErrClosed
+    }
+
+    // Take the bottom element, which goes in place of the top one.
+    bottom := slot(q.size)
+    q.size--
+    n := q.node(bottom)
+    n.mu.Lock()
+    q.wakePushes()
+    q.mu.Unlock()
+
+    x := n.item
+    n.item = zero
+    n.tag = tagEmpty
+    n.mu.Unlock()
+
+    root := q.node(1)
+    root.mu.Lock()
+    if root.tag == tagEmpty {
+        // The bottom was the top.
+        root.mu.Unlock()
+        return x, nil
+    }
+    x, root.item = root.item, x
+    root.tag = tagAvailable
+
+    // Sift down, holding the node and then its children.
+    i, cur := 1, root
+    for 2 * i <= int(q.allocated.Load()) {
+        left, right := q.node(2 * i), q.node(2 * i + 1)
+        left.mu.Lock()
+        right.mu.Lock()
+        if left.tag == tagEmpty {
+            right.mu.Unlock()
+            left.mu.Unlock()
+            break
+        }
+        child, c := 2 * i, left
+        if right.tag == tagEmpty || q.less(left.item, right.item) {
+            right.mu.Unlock()
+        } else {
+            left.mu.Unlock()
+            child, c = 2 * i + 1, right
+        }
+        if !q.less(c.item, cur.item) {
+            c.mu.Unlock()
+            break
+        }
+        cur.item, c.item = c.item, cur.item
+        cur.tag, c.tag = c.tag, cur.tag
+        cur.mu.Unlock()
+        i, cur = child, c
+    }
+    cur.mu.Unlock()
+    return x, nil
+}
+
+func (q *Concurrent[T]) Peek() (T, bool) {
+    var zero T
+    if q.allocated.Load() == 0 {
+        return zero, false
+    }
+    root := q.node(1)
+    root.mu.Lock()
+    defer root.mu.Unlock()
+    if root.tag == tagEmpty {
+        return zero, false
+    }
+    return root.item, true
+}
+
+func (q *Concurrent[T]) Len() int {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return q.size
+}
+
+// Close makes pushes fail from now on, and wakes every waiter.
+// Elements already in the queue can still be popped.
+func (q *Concurrent[T]) Close() {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.closed {
+        return
+    }
+    q.closed = true
+    q.wakePops()
+    q.wakePushes()
+}
@@ -0,0 +1,134 @@
+package heap
+
+import "cmp"
+
+// Generic heap, ordered by less
+
+// elements implements Interface, so Heap can use up & down
+type elements[T any] struct {
+    items []T
+    less func(a, b T) bool
+}
+
+func (e *elements[T]) Len() int {
+    return len(e.items)
+}
+
+func (e *elements[T]) Less(i, j int) bool {
+    return e.less(e.items[i], e.items[j])
+}
+
+func (e *elements[T]) Swap(i, j int) {
+    e.items[i], e.items[j] = e.items[j], e.items[i]
+}
+
+func (e *elements[T]) Push(x interface{}) {
+    e.items = append(e.items, x.(T))
+}
+
+func (e *elements[T]) Pop() interface{} {
+    return e.pop()
+}
+
+func (e *elements[T]) pop() T {
+    n := len(e.items)
+    x := e.items[n - 1]
+    var zero T
+    // Don't keep the popped element alive.
+    e.items[n - 1] = zero
+    e.items = e.items[:n - 1]
+    return x
+}
+
+// The element for which less is true against all others is on top.
+type Heap[T any] struct {
+    e elements[T]
+    d Dary
+}
+
+func New[T any](less func(a, b T) bool) *Heap[T] {
+    return NewDary(2, less)
+}
+
+// NewDary returns a heap in which each node has d children. It panics if d < 2.
+func NewDary[T any](d int, less func(a, b T) bool) *Heap[T] {
+    checkArity(d)
+    return &Heap[T]{e: elements[T]{less: less}, d: Dary(d)}
+}
+
+func checkArity(d int) {
+    if d < 2 {
+        panic("heap: arity must be at least 2")
+    }
+}
+
+func NewMin[T cmp.Ordered]() *Heap[T] {
+    return New(cmp.Less[T])
+}
+
+func NewMax[T cmp.Ordered]() *Heap[T] {
+    return New(func(a, b T) bool {
+        return cmp.Less(b, a)
+    })
+}
+
+// Replace the contents of h with items, in O(n). h keeps items
+// and reorders it in place.
+func (h *Heap[T]) Init(items []T) {
+    h.e.items = items
+    h.d.Init(&h.e)
+}
+
+func (h *Heap[T]) Len() int {
+    return len(h.e.items)
+}
+
+func (h *Heap[T]) Push(x T) {
+    h.e.items = append(h.e.items, x)
+    h.d.up(&h.e, len(h.e.items) - 1)
+}
+
+// Pop removes and returns the top element. It panics if h is empty.
+func (h *Heap[T]) Pop() T {
+    n := len(h.e.items)
+    h.e.Swap(0, n - 1)
+    h.d.down(&h.e, 0, n - 1)
+    return h.e.pop()
+}
+
+// Peek returns the top element without removing it, and false if h is empty.
+func (h *Heap[T]) Peek() (T, bool) {
+    if len(h.e.items) == 0 {
+        var zero T
+        return zero, false
+    }
+    return h.e.items[0], true
+}
+
+// At returns the element at index i, 0 <= i < Len(), for use with Fix & Remove.
+func (h *Heap[T]) At(i int) T {
+    return h.e.items[i]
+}
+
+// Set replaces the element at index i and restores the heap order.
+func (h *Heap[T]) Set(i int, x T) {
+    h.e.items[i] = x
+    h.Fix(i)
+}
+
+func (h *Heap[T]) Remove(i int) T {
+    n := len(h.e.items)
+    if i != n - 1 {
+        h.e.Swap(i, n - 1)
+        if !h.d.down(&h.e, i, n - 1) {
+            h.d.up(&h.e, i)
+        }
+    }
+    return h.e.pop()
+}
+
+func (h *Heap[T]) Fix(i int) {
+    if !h.d.down(&h.e, i, len(h.e.items)) {
+        h.d.up(&h.e, i)
+    }
+}
@@ -11,48 +11,73 @@ type Interface interface {
     Pop() interface{}
 }
 
+// The number of children of each node. The functions below are
+// those of a binary heap, Dary(2); a 4-ary heap has fewer levels
+// and keeps the children of a node in one cache line.
+type Dary int
+
 func Init(h Interface) {
-    n := h.Len()
-    // A heap is a complete binary tree, so there are ceil(n / 2) leaf nodes.
-    for i := n / 2 - 1; i >= 0; i-- {
-        down(h, i, n)
-    }
+    Dary(2).Init(h)
 }
 
 func Push(h Interface, x any) {
-    h.Push(x)
-    up(h, h.Len() - 1)
+    Dary(2).Push(h, x)
 }
 
 // Pop removes and returns the minimum element (according to Less) from the heap.
 // We should always pop the elmement with the largest index from h.
 func Pop(h Interface) any {
+    return Dary(2).Pop(h)
+}
+
+func Remove(h Interface, i int) any {
+    return Dary(2).Remove(h, i)
+}
+
+func Fix(h Interface, i int) {
+    Dary(2).Fix(h, i)
+}
+
+func (d Dary) Init(h Interface) {
+    n := h.Len()
+    // Nodes after the parent of the last node are leaves.
+    for i := (n - 2) / int(d); i >= 0; i-- {
+        d.down(h, i, n)
+    }
+}
+
+func (d Dary) Push(h Interface, x any) {
+    h.Push(x)
+    d.up(h, h.Len() - 1)
+}
+
+func (d Dary) Pop(h Interface) any {
     n := h.Len()
     h.Swap(0, n - 1)
-    down(h, 0, n - 1)
+    d.down(h, 0, n - 1)
     return h.Pop()
 }
 
-func Remove(h Interface, i int) any {
+func (d Dary) Remove(h Interface, i int) any {
     n := h.Len()
     if i != n - 1 {
         h.Swap(i, n - 1)
-        if !down(h, i, n - 1) {
-            up(h, i)
+        if !d.down(h, i, n - 1) {
+            d.up(h, i)
         }
     }
     return h.Pop()
 }
 
-func Fix(h Interface, i int) {
-    if !down(h, i, h.Len()) {
-        up(h, i)
+func (d Dary) Fix(h Interface, i int) {
+    if !d.down(h, i, h.Len()) {
+        d.up(h, i)
     }
 }
 
-func up(h Interface, i int) {
+func (d Dary) up(h Interface, i int) {
     for {
-        j := (i - 1) / 2
+        j := (i - 1) / int(d)
         if i == 0 || !h.Less(i, j) {
             break
         }
@@ -61,16 +86,19 @@ func up(h Interface, i int) {
     }
 }
 
-func down(h Interface, i int, n int) bool {
+func (d Dary) down(h Interface, i int, n int) bool {
     p := i
     for {
-        l := 2 * i + 1
+        l := int(d) * i + 1
         if l >= n || l < 0 {
             break
         }
+        // The least of the children l .. l+d-1.
         c := l
-        if r := l + 1; r < n && h.Less(r, l) {
-            c = r
+        for r := l + 1; r < l + int(d) && r < n; r++ {
+            if h.Less(r, c) {
+                c = r
+            }
         }
         if !h.Less(c, i) {
             break
@@ -0,0 +1,150 @@
+package heap
+
+import "cmp"
+
+// Indexed priority queue, keyed by a comparable ID
+
+type entry[K comparable, P any] struct {
+    key K
+    priority P
+}
+
+// entries implements Interface, and keeps index up to date on every Swap
+type entries[K comparable, P any] struct {
+    items []entry[K, P]
+    index map[K]int
+    less func(a, b P) bool
+}
+
+func (e *entries[K, P]) Len() int {
+    return len(e.items)
+}
+
+func (e *entries[K, P]) Less(i, j int) bool {
+    return e.less(e.items[i].priority, e.items[j].priority)
+}
+
+func (e *entries[K, P]) Swap(i, j int) {
+    e.items[i], e.items[j] = e.items[j], e.items[i]
+    e.index[e.items[i].key] = i
+    e.index[e.items[j].key] = j
+}
+
+func (e *entries[K, P]) Push(x interface{}) {
+    item := x.(entry[K, P])
+    e.index[item.key] = len(e.items)
+    e.items = append(e.items, item)
+}
+
+func (e *entries[K, P]) Pop() interface{} {
+    return e.pop()
+}
+
+func (e *entries[K, P]) pop() entry[K, P] {
+    n := len(e.items)
+    item := e.items[n - 1]
+    e.items[n - 1] = entry[K, P]{}
+    e.items = e.items[:n - 1]
+    delete(e.index, item.key)
+    return item
+}
+
+// The key whose priority is less than all others is on top. Update,
+// Remove & Contains find a key through a position map, so callers
+// never need to know indexes.
+type Indexed[K comparable, P any] struct {
+    e entries[K, P]
+    d Dary
+}
+
+func NewIndexed[K comparable, P any](less func(a, b P) bool) *Indexed[K, P] {
+    return NewIndexedDary[K](2, less)
+}
+
+// NewIndexedDary returns a queue in which each node has d children. It panics if d < 2.
+func NewIndexedDary[K comparable, P any](d int, less func(a, b P) bool) *Indexed[K, P] {
+    checkArity(d)
+    return &Indexed[K, P]{e: entries[K, P]{index: map[K]int{}, less: less}, d: Dary(d)}
+}
+
+func NewIndexedMin[K comparable, P cmp.Ordered]() *Indexed[K, P] {
+    return NewIndexed[K](cmp.Less[P])
+}
+
+func NewIndexedMax[K comparable, P cmp.Ordered]() *Indexed[K, P] {
+    return NewIndexed[K](func(a, b P) bool {
+        return cmp.Less(b, a)
+    })
+}
+
+func (h *Indexed[K, P]) Len() int {
+    return len(h.e.items)
+}
+
+func (h *Indexed[K, P]) Contains(key K) bool {
+    _, ok := h.e.index[key]
+    return ok
+}
+
+// Priority returns the priority of key, and false if key is not in h.
+func (h *Indexed[K, P]) Priority(key K) (P, bool) {
+    i, ok := h.e.index[key]
+    if !ok {
+        var zero P
+        return zero, false
+    }
+    return h.e.items[i].priority, true
+}
+
+// Push adds key with priority, or updates its priority if key is already in h.
+func (h *Indexed[K, P]) Push(key K, priority P) {
+    if h.Update(key, priority) {
+        return
+    }
+    h.e.Push(entry[K, P]{key, priority})
+    h.d.up(&h.e, len(h.e.items) - 1)
+}
+
+// Update changes the priority of key, in either direction, and
+// reports whether key was in h. O(log n).
+func (h *Indexed[K, P]) Update(key K, priority P) bool {
+    i, ok := h.e.index[key]
+    if !ok {
+        return false
+    }
+    h.e.items[i].priority = priority
+    h.d.Fix(&h.e, i)
+    return true
+}
+
+// Pop removes and returns the top key and its priority. It panics if h is empty.
+func (h *Indexed[K, P]) Pop() (K, P) {
+    n := len(h.e.items)
+    h.e.Swap(0, n - 1)
+    h.d.down(&h.e, 0, n - 1)
+    item := h.e.pop()
+    return item.key, item.priority
+}
+
+// Peek returns the top key and its priority without removing them,
+// and false if h is empty.
+func (h *Indexed[K, P]) Peek() (K, P, bool) {
+    if len(h.e.items) == 0 {
+        var key K
+        var priority P
+        return key, priority, false
+    }
+    item := h.e.items[0]
+    return item.key, item.priority, true
+}
+
+// Remove removes key, returning its priority, and false if key was not in h.
+func (h *Indexed[K, P]) Remove(key K) (P, bool) {
+    i, ok := h.e.index[key]
+    if !ok {
+        var zero P
+        return zero, false
+    }
+    item := h.d.Remove(&h.e, i).(entry[K, P])
+    return item.priority, true
+}
@@ -0,0 +1,52 @@
+package heap
+
+// Running median from two heaps: the lower half, greatest on top,
+// and the upper half, least on top. O(log n) per element.
+
+type Median[T any] struct {
+    low *Heap[T]
+    high *Heap[T]
+    less func(a, b T) bool
+}
+
+func NewMedian[T any](less func(a, b T) bool) *Median[T] {
+    return &Median[T]{
+        low: New(func(a, b T) bool {
+            return less(b, a)
+        }),
+        high: New(less),
+        less: less,
+    }
+}
+
+func (m *Median[T]) Add(x T) {
+    if top, ok := m.low.Peek(); ok && !m.less(top, x) {
+        m.low.Push(x)
+    } else {
+        m.high.Push(x)
+    }
+    // Keep the lower half the same size as the upper, or one larger.
+    if m.low.Len() > m.high.Len() + 1 {
+        m.high.Push(m.low.Pop())
+    } else if m.high.Len() > m.low.Len() {
+        m.low.Push(m.high.Pop())
+    }
+}
+
+func (m *Median[T]) Len() int {
+    return m.low.Len() + m.high.Len()
+}
+
+// Median returns the middle element, twice for an odd count, or the
+// two middle elements for an even count, and false if nothing was added.
+func (m *Median[T]) Median() (lo T, hi T, ok bool) {
+    lo, ok = m.low.Peek()
+    if !ok {
+        return lo, lo, false
+    }
+    if m.high.Len() == m.low.Len() {
+        hi, _ = m.high.Peek()
+        return lo, hi, true
+    }
+    return lo, lo, true
+}
@@ -0,0 +1,51 @@
+package heap
+
+import "iter"
+
+// k-way merge of sorted sequences in O(log k) per element.
+
+type head[T any] struct {
+    value T
+    source int
+}
+
+// Merge yields the elements of seqs, each sorted by less, as one
+// sorted sequence. Equal elements come in the order of their
+// sources. Each source is read only as far as it needs to be.
+func Merge[T any](less func(a, b T) bool, seqs ...iter.Seq[T]) iter.Seq[T] {
+    return func(yield func(T) bool) {
+        nexts := make([]func() (T, bool), len(seqs))
+        for i, seq := range seqs {
+            next, stop := iter.Pull(seq)
+            defer stop()
+            nexts[i] = next
+        }
+
+        h := New(func(a, b head[T]) bool {
+            if less(a.value, b.value) {
+                return true
+            }
+            if less(b.value, a.value) {
+                return false
+            }
+            return a.source < b.source
+        })
+        for i, next := range nexts {
+            if x, ok := next(); ok {
+                h.Push(head[T]{x, i})
+            }
+        }
+
+        for h.Len() > 0 {
+            top := h.At(0)
+            if !yield(top.value) {
+                return
+            }
+            if x, ok := nexts[top.source](); ok {
+                h.Set(0, head[T]{x, top.source})
+            } else {
+                h.Pop()
+            }
+        }
+    }
+}
@@ -0,0 +1,14 @@
+package heap
+
+// What every priority queue in data-structure offers, so that one
+// conformance suite can run against all of them.
+type Queue[T any] interface {
+    Push(x T)
+    // Pop removes and returns the top element. It panics if the queue is empty.
+    Pop() T
+    // Peek returns the top element, and false if the queue is empty.
+    Peek() (T, bool)
+    Len() int
+}
+
+var _ Queue[int] = (*Heap[int])(nil)
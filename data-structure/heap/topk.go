@@ -0,0 +1,48 @@
+package heap
+
+// Bounded top-K: the k greatest elements seen, under less, in O(log k)
+// per element. For the k least, reverse less.
+
+type TopK[T any] struct {
+    k int
+    h *Heap[T] // least of the k on top, to be pushed out first
+    less func(a, b T) bool
+}
+
+// NewTopK panics if k < 0.
+func NewTopK[T any](k int, less func(a, b T) bool) *TopK[T] {
+    if k < 0 {
+        panic("heap: negative k for NewTopK")
+    }
+    return &TopK[T]{k: k, h: New(less), less: less}
+}
+
+// Add offers x, and reports whether it is among the top k so far.
+func (t *TopK[T]) Add(x T) bool {
+    if t.h.Len() < t.k {
+        t.h.Push(x)
+        return true
+    }
+    if t.k == 0 || !t.less(t.h.At(0), x) {
+        return false
+    }
+    t.h.Set(0, x)
+    return true
+}
+
+func (t *TopK[T]) Len() int {
+    return t.h.Len()
+}
+
+// Items returns the top k, greatest first. t is left as it was.
+func (t *TopK[T]) Items() []T {
+    items := make([]T, t.h.Len())
+    copy(items, t.h.e.items)
+    h := New(t.less)
+    h.Init(items)
+    for i := len(items) - 1; i >= 0; i-- {
+        // Popping moves the least to the end, so items ends up sorted.
+        items[i] = h.Pop()
+    }
+    return items
+}
@@ -0,0 +1,150 @@
+package pairing
+
+import "cmp"
+
+// Pairing heap: O(1) Insert, Meld & DecreaseKey, and O(log n)
+// amortized DeleteMin.
+
+// A handle on an inserted element, for DecreaseKey. It is only
+// valid until its element is deleted.
+type Node[T any] struct {
+    value T
+    child *Node[T]
+    next *Node[T] // right sibling
+    prev *Node[T] // left sibling, or parent for a leftmost child
+}
+
+func (n *Node[T]) Value() T {
+    return n.value
+}
+
+type Heap[T any] struct {
+    root *Node[T]
+    size int
+    less func(a, b T) bool
+}
+
+func New[T any](less func(a, b T) bool) *Heap[T] {
+    return &Heap[T]{less: less}
+}
+
+func NewMin[T cmp.Ordered]() *Heap[T] {
+    return New(cmp.Less[T])
+}
+
+func (h *Heap[T]) Len() int {
+    return h.size
+}
+
+func (h *Heap[T]) Insert(x T) *Node[T] {
+    n := &Node[T]{value: x}
+    h.root = h.link(h.root, n)
+    h.size++
+    return n
+}
+
+func (h *Heap[T]) Push(x T) {
+    h.Insert(x)
+}
+
+func (h *Heap[T]) Peek() (T, bool) {
+    if h.root == nil {
+        var zero T
+        return zero, false
+    }
+    return h.root.value, true
+}
+
+// DeleteMin removes and returns the least element. It panics if h is empty.
+func (h *Heap[T]) DeleteMin() T {
+    root := h.root
+    h.root = h.mergePairs(root.child)
+    root.child = nil
+    h.size--
+    return root.value
+}
+
+func (h *Heap[T]) Pop() T {
+    return h.DeleteMin()
+}
+
+// DecreaseKey lowers the value of n, which must be in h, to x.
+// It panics if x is greater than the current value.
+func (h *Heap[T]) DecreaseKey(n *Node[T], x T) {
+    if h.less(n.value, x) {
+        panic("pairing: DecreaseKey to a greater value")
+    }
+    n.value = x
+    if n == h.root {
+        return
+    }
+    // Cut the subtree at n and meld it back in.
+    if n.prev.child == n {
+        n.prev.child = n.next
+    } else {
+        n.prev.next = n.next
+    }
+    if n.next != nil {
+        n.next.prev = n.prev
+    }
+    n.next, n.prev = nil, nil
+    h.root = h.link(h.root, n)
+}
+
+// Meld moves all elements of other, which must be ordered the
+// same way, into h, leaving other empty.
+func (h *Heap[T]) Meld(other *Heap[T]) {
+    if other == h {
+        return
+    }
+    h.root = h.link(h.root, other.root)
+    h.size += other.size
+    other.root, other.size = nil, 0
+}
+
+// link makes the greater of two roots the leftmost child of the other.
+func (h *Heap[T]) link(a, b *Node[T]) *Node[T] {
+    if a == nil {
+        return b
+    }
+    if b == nil {
+        return a
+    }
+    if h.less(b.value, a.value) {
+        a, b = b, a
+    }
+    b.prev = a
+    b.next = a.child
+    if a.child != nil {
+        a.child.prev = b
+    }
+    a.child = b
+    return a
+}
+
+// The standard two passes: link siblings in pairs from left to
+// right, then link the pairs into one tree from right to left.
+func (h *Heap[T]) mergePairs(first *Node[T]) *Node[T] {
+    var pairs *Node[T] // in reverse order, chained through next
+    for first != nil {
+        a, b := first, first.next
+        first = nil
+        if b != nil {
+            first = b.next
+            b.next, b.prev = nil, nil
+        }
+        a.next, a.prev = nil, nil
+        p := h.link(a, b)
+        p.next = pairs
+        pairs = p
+    }
+
+    var root *Node[T]
+    for pairs != nil {
+        next := pairs.next
+        pairs.next = nil
+        root = h.link(root, pairs)
+        pairs = next
+    }
+    return root
+}
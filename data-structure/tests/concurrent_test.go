@@ -0,0 +1,299 @@
+package tests
+
+import (
+    "context"
+    "errors"
+    "math/rand"
+    "sync"
+    "testing"
+    "time"
+    "data-structure/heap"
+)
+
+func lessInt(a, b int) bool {
+    return a < b
+}
+
+func TestConcurrentOrder(t *testing.T) {
+    q := heap.NewConcurrent(lessInt, 0)
+    for _, x := range []int{5, 2, 8, 1} {
+        if err := q.TryPush(x); err != nil {
+            t.Fatalf("push: %v", err)
+        }
+    }
+    for _, want := range []int{1, 2, 5, 8} {
+        if x, err := q.PopWait(context.Background()); err != nil || x != want {
+            t.Fatalf("pop: want %v, got %v %v", want, x, err)
+        }
+    }
+    if _, ok := q.TryPop(); ok {
+        t.Fatalf("expected an empty queue")
+    }
+}
+
+func TestConcurrentPopWait(t *testing.T) {
+    q := heap.NewConcurrent(lessInt, 0)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+    defer cancel()
+    if _, err := q.PopWait(ctx); err != context.DeadlineExceeded {
+        t.Fatalf("expected the deadline, got %v", err)
+    }
+
+    done := make(chan int)
+    go func() {
+        x, _ := q.PopWait(context.Background())
+        done <- x
+    }()
+    time.Sleep(10 * time.Millisecond)
+    q.TryPush(7)
+    if x := <-done; x != 7 {
+        t.Fatalf("pop: want 7, got %v", x)
+    }
+}
+
+func TestConcurrentCapacity(t *testing.T) {
+    q := heap.NewConcurrent(lessInt, 2)
+    q.TryPush(1)
+    q.TryPush(2)
+    if err := q.TryPush(3); err != heap.ErrFull {
+        t.Fatalf("expected ErrFull, got %v", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+    defer cancel()
+    if err := q.Push(ctx, 3); err != context.DeadlineExceeded {
+        t.Fatalf("expected the deadline, got %v", err)
+    }
+
+    // a pop makes room for a blocked push.
+    done := make(chan error)
+    go func() {
+        done <- q.Push(context.Background(), 3)
+    }()
+    time.Sleep(10 * time.Millisecond)
+    q.TryPop()
+    if err := <-done; err != nil || q.Len() != 2 {
+        t.Fatalf("push: %v, len %v", err, q.Len())
+    }
+}
+
+func TestConcurrentClose(t *testing.T) {
+    q := heap.NewConcurrent(lessInt, 1)
+    q.TryPush(1)
+
+    pushed := make(chan error)
+    go func() {
+        pushed <- q.Push(context.Background(), 2)
+    }()
+    time.Sleep(10 * time.Millisecond)
+    q.Close()
+    if err := <-pushed; err != heap.ErrClosed {
+        t.Fatalf("expected a blocked push to fail, got %v", err)
+    }
+    if err := q.TryPush(3); err != heap.ErrClosed {
+        t.Fatalf("expected ErrClosed, got %v", err)
+    }
+
+    // what is left can still be popped.
+    if x, err := q.PopWait(context.Background()); err != nil || x != 1 {
+        t.Fatalf("pop: want 1, got %v %v", x, err)
+    }
+    if _, err := q.PopWait(context.Background()); err != heap.ErrClosed {
+        t.Fatalf("expected ErrClosed, got %v", err)
+    }
+
+    q2 := heap.NewConcurrent(lessInt, 0)
+    popped := make(chan error)
+    go func() {
+        _, err := q2.PopWait(context.Background())
+        popped <- err
+    }()
+    time.Sleep(10 * time.Millisecond)
+    q2.Close()
+    if err := <-popped; err != heap.ErrClosed {
+        t.Fatalf("expected a blocked pop to fail, got %v", err)
+    }
+}
+
+// many producers and consumers through a small queue; run with -race.
+func TestConcurrentStress(t *testing.T) {
+    const producers = 8
+    const consumers = 8
+    const n = 2000
+    q := heap.NewConcurrent(lessInt, 16)
+
+    var wg sync.WaitGroup
+    for p := 0; p < producers; p++ {
+        wg.Add(1)
+        go func(p int) {
+            defer wg.Done()
+            for i := 0; i < n; i++ {
+                if err := q.Push(context.Background(), p * n + i); err != nil {
+                    t.Errorf("push: %v", err)
+                    return
+                }
+                if q.Len() > 16 {
+                    t.Errorf("len %v over capacity", q.Len())
+                }
+            }
+        }(p)
+    }
+
+    seen := make([][]int, consumers)
+    var consumed sync.WaitGroup
+    for c := 0; c < consumers; c++ {
+        consumed.Add(1)
+        go func(c int) {
+            defer consumed.Done()
+            for {
+                x, err := q.PopWait(context.Background())
+                if errors.Is(err, heap.ErrClosed) {
+                    return
+                }
+                seen[c] = append(seen[c], x)
+            }
+        }(c)
+    }
+
+    wg.Wait()
+    q.Close()
+    consumed.Wait()
+
+    count := make([]int, producers * n)
+    for _, xs := range seen {
+        for _, x := range xs {
+            count[x]++
+        }
+    }
+    for x, c := range count {
+        if c != 1 {
+            t.Fatalf("%v popped %v times", x, c)
+        }
+    }
+}
+
+// pushes and pops racing each other leave the heap in order.
+func TestConcurrentMixedOrder(t *testing.T) {
+    const workers = 8
+    for round := 0; round < 200; round++ {
+        q := heap.NewConcurrent(lessInt, 0)
+        var wg sync.WaitGroup
+        var mu sync.Mutex
+        left := 0
+        for w := 0; w < workers; w++ {
+            wg.Add(1)
+            go func(w int) {
+                defer wg.Done()
+                r := rand.New(rand.NewSource(int64(round * workers + w)))
+                pushed, popped := 0, 0
+                for i := 0; i < 100; i++ {
+                    if r.Intn(3) == 0 {
+                        if _, ok := q.TryPop(); ok {
+                            popped++
+                        }
+                    } else if q.TryPush(r.Intn(1000)) == nil {
+                        pushed++
+                    }
+                }
+                mu.Lock()
+                left += pushed - popped
+                mu.Unlock()
+            }(w)
+        }
+        wg.Wait()
+
+        if q.Len() != left {
+            t.Fatalf("round %v: expected %v left, got %v", round, left, q.Len())
+        }
+        prev := -1
+        for i := 0; i < left; i++ {
+            x, err := q.PopWait(context.Background())
+            if err != nil {
+                t.Fatalf("round %v: pop: %v", round, err)
+            }
+            if x < prev {
+                t.Fatalf("round %v: popped %v after %v", round, x, prev)
+            }
+            prev = x
+        }
+        if q.Len() != 0 {
+            t.Fatalf("round %v: expected an empty queue, got %v", round, q.Len())
+        }
+    }
+}
+
+// the alternative: a heap behind a mutex, polled when empty.
+type mutexHeap struct {
+    mu sync.Mutex
+    h *heap.Heap[int]
+}
+
+func (m *mutexHeap) push(x int) {
+    m.mu.Lock()
+    m.h.Push(x)
+    m.mu.Unlock()
+}
+
+func (m *mutexHeap) pop() (int, bool) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.h.Len() == 0 {
+        return 0, false
+    }
+    return m.h.Pop(), true
+}
+
+func BenchmarkConcurrent(b *testing.B) {
+    b.Run("concurrent", func(b *testing.B) {
+        q := heap.NewConcurrent(lessInt, 0)
+        b.RunParallel(func(pb *testing.PB) {
+            i := 0
+            for pb.Next() {
+                q.TryPush(i)
+                q.PopWait(context.Background())
+                i++
+            }
+        })
+    })
+    // with a deep heap, pushes and pops mostly lock different nodes.
+    b.Run("concurrent-deep", func(b *testing.B) {
+        q := heap.NewConcurrent(lessInt, 0)
+        for i := 0; i < 1 << 16; i++ {
+            q.TryPush(i)
+        }
+        b.RunParallel(func(pb *testing.PB) {
+            i := 0
+            for pb.Next() {
+                q.TryPush(i)
+                q.PopWait(context.Background())
+                i++
+            }
+        })
+    })
+    b.Run("mutex", func(b *testing.B) {
+        m := &mutexHeap{h: heap.NewMin[int]()}
+        b.RunParallel(func(pb *testing.PB) {
+            i := 0
+            for pb.Next() {
+                m.push(i)
+                m.pop()
+                i++
+            }
+        })
+    })
+    b.Run("mutex-deep", func(b *testing.B) {
+        m := &mutexHeap{h: heap.NewMin[int]()}
+        for i := 0; i < 1 << 16; i++ {
+            m.push(i)
+        }
+        b.RunParallel(func(pb *testing.PB) {
+            i := 0
+            for pb.Next() {
+                m.push(i)
+                m.pop()
+                i++
+            }
+        })
+    })
+}
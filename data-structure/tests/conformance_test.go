@@ -0,0 +1,145 @@
+package tests
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+    "data-structure/binomial"
+    "data-structure/heap"
+    "data-structure/pairing"
+)
+
+// One suite for every heap.Queue in data-structure.
+var queues = map[string]func() heap.Queue[int]{
+    "binary": func() heap.Queue[int] { return heap.NewMin[int]() },
+    "4-ary": func() heap.Queue[int] {
+        return heap.NewDary(4, func(a, b int) bool { return a < b })
+    },
+    "pairing": func() heap.Queue[int] { return pairing.NewMin[int]() },
+    "binomial": func() heap.Queue[int] { return binomial.NewMin[int]() },
+}
+
+func TestQueueConformance(t *testing.T) {
+    for name, newQueue := range queues {
+        t.Run(name, func(t *testing.T) {
+            q := newQueue()
+            if _, ok := q.Peek(); ok || q.Len() != 0 {
+                t.Fatalf("expected an empty queue")
+            }
+
+            // A sorted model, checked after every operation.
+            r := rand.New(rand.NewSource(1))
+            model := []int{}
+            for i := 0; i < 5000; i++ {
+                if r.Intn(3) != 0 || len(model) == 0 {
+                    x := r.Intn(500)
+                    q.Push(x)
+                    model = append(model, x)
+                    sort.Ints(model)
+                } else {
+                    if x := q.Pop(); x != model[0] {
+                        t.Fatalf("pop: want %v, got %v", model[0], x)
+                    }
+                    model = model[1:]
+                }
+                if q.Len() != len(model) {
+                    t.Fatalf("len: want %v, got %v", len(model), q.Len())
+                }
+                if len(model) > 0 {
+                    if x, ok := q.Peek(); !ok || x != model[0] {
+                        t.Fatalf("peek: want %v, got %v", model[0], x)
+                    }
+                }
+            }
+        })
+    }
+}
+
+// What the mergeable heaps add to heap.Queue.
+type mergeable[N any, H any] interface {
+    heap.Queue[int]
+    Insert(x int) N
+    DecreaseKey(n N, x int)
+    Meld(other H)
+}
+
+func testMergeable[N any, H mergeable[N, H]](t *testing.T, newHeap func() H, value func(N) int) {
+    r := rand.New(rand.NewSource(1))
+    a, b := newHeap(), newHeap()
+    // Distinct values, so that a popped value names its node.
+    used := map[int]bool{}
+    fresh := func(x int) int {
+        for used[x] {
+            x--
+        }
+        used[x] = true
+        return x
+    }
+    nodes := []N{}
+    for i := 0; i < 1000; i++ {
+        x := fresh(r.Intn(1000))
+        if i % 2 == 0 {
+            nodes = append(nodes, a.Insert(x))
+        } else {
+            nodes = append(nodes, b.Insert(x))
+        }
+    }
+
+    a.Meld(b)
+    if a.Len() != 1000 || b.Len() != 0 {
+        t.Fatalf("meld: lens %v %v", a.Len(), b.Len())
+    }
+    if _, ok := b.Peek(); ok {
+        t.Fatalf("meld: expected other to be empty")
+    }
+
+    // Decrease keys between pops, once DeleteMin has rebuilt the trees.
+    for len(nodes) > 0 {
+        if r.Intn(2) == 0 {
+            n := nodes[r.Intn(len(nodes))]
+            a.DecreaseKey(n, fresh(value(n) - r.Intn(1000)))
+            continue
+        }
+        least := 0
+        for i, n := range nodes {
+            if value(n) < value(nodes[least]) {
+                least = i
+            }
+        }
+        if x := a.Pop(); x != value(nodes[least]) {
+            t.Fatalf("pop: want %v, got %v", value(nodes[least]), x)
+        }
+        nodes = append(nodes[:least], nodes[least + 1:]...)
+        if a.Len() != len(nodes) {
+            t.Fatalf("len: want %v, got %v", len(nodes), a.Len())
+        }
+    }
+}
+
+func TestMergeableConformance(t *testing.T) {
+    t.Run("pairing", func(t *testing.T) {
+        testMergeable(t, pairing.NewMin[int], (*pairing.Node[int]).Value)
+    })
+    t.Run("binomial", func(t *testing.T) {
+        testMergeable(t, binomial.NewMin[int], (*binomial.Node[int]).Value)
+    })
+}
+
+func TestDecreaseKeyGreater(t *testing.T) {
+    expectPanic := func(name string, decrease func()) {
+        defer func() {
+            if recover() == nil {
+                t.Errorf("%v: expected DecreaseKey to a greater value to panic", name)
+            }
+        }()
+        decrease()
+    }
+    expectPanic("pairing", func() {
+        h := pairing.NewMin[int]()
+        h.DecreaseKey(h.Insert(1), 2)
+    })
+    expectPanic("binomial", func() {
+        h := binomial.NewMin[int]()
+        h.DecreaseKey(h.Insert(1), 2)
+    })
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+    "testing"
+    "data-structure/heap"
+)
+
+func checkDary(t *testing.T, h IntHeap, d int) {
+    for i := 1; i < len(h); i++ {
+        if h[i] < h[(i - 1) / d] {
+            t.Fatalf("d=%v: h[%v] = %v is less than its parent: %v", d, i, h[i], h)
+        }
+    }
+}
+
+func TestDary(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    for _, d := range []int{2, 3, 4, 8} {
+        dary := heap.Dary(d)
+        h := &IntHeap{}
+        for i := 0; i < 100; i++ {
+            *h = append(*h, r.Intn(1000))
+        }
+        dary.Init(h)
+        checkDary(t, *h, d)
+
+        for i := 0; i < 1000; i++ {
+            switch r.Intn(4) {
+            case 0:
+                dary.Push(h, r.Intn(1000))
+            case 1:
+                if h.Len() > 0 {
+                    min := (*h)[0]
+                    if x := dary.Pop(h); x != min {
+                        t.Fatalf("d=%v: pop: want %v, got %v", d, min, x)
+                    }
+                }
+            case 2:
+                if h.Len() > 0 {
+                    dary.Remove(h, r.Intn(h.Len()))
+                }
+            case 3:
+                if h.Len() > 0 {
+                    i := r.Intn(h.Len())
+                    (*h)[i] = r.Intn(1000)
+                    dary.Fix(h, i)
+                }
+            }
+            checkDary(t, *h, d)
+        }
+    }
+}
+
+func TestGenericDary(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    for _, d := range []int{2, 4, 8} {
+        h := heap.NewDary(d, func(a, b int) bool {
+            return a < b
+        })
+        items := make([]int, 1000)
+        for i := range items {
+            items[i] = r.Intn(1000)
+            h.Push(items[i])
+        }
+        sort.Ints(items)
+        for _, want := range items {
+            if x := h.Pop(); x != want {
+                t.Fatalf("d=%v: pop: want %v, got %v", d, want, x)
+            }
+        }
+    }
+}
+
+// Push-heavy: every element pushed, a tenth popped.
+func BenchmarkDaryPush(b *testing.B) {
+    for _, d := range []int{2, 4, 8} {
+        b.Run(fmt.Sprintf("d=%d", d), func(b *testing.B) {
+            r := rand.New(rand.NewSource(1))
+            h := heap.NewDary(d, func(a, b int) bool {
+                return a < b
+            })
+            for i := 0; i < b.N; i++ {
+                h.Push(r.Int())
+                if i % 10 == 0 {
+                    h.Pop()
+                }
+            }
+        })
+    }
+}
+
+// Pop-heavy: a large heap drained and refilled.
+func BenchmarkDaryPop(b *testing.B) {
+    const n = 1 << 16
+    for _, d := range []int{2, 4, 8} {
+        b.Run(fmt.Sprintf("d=%d", d), func(b *testing.B) {
+            r := rand.New(rand.NewSource(1))
+            h := heap.NewDary(d, func(a, b int) bool {
+                return a < b
+            })
+            items := make([]int, n)
+            for i := range items {
+                items[i] = r.Int()
+            }
+            h.Init(items)
+            b.ResetTimer()
+            for i := 0; i < b.N; i++ {
+                h.Pop()
+                h.Push(r.Int())
+            }
+        })
+    }
+}
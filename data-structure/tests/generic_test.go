@@ -0,0 +1,71 @@
+package tests
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+    "data-structure/heap"
+)
+
+func TestGenericHeap(t *testing.T) {
+    h := heap.NewMin[int]()
+    h.Init([]int{2, 1, 5})
+
+    h.Push(3)
+    if x, ok := h.Peek(); !ok || x != 1 {
+        t.Errorf("peek: %v %v", x, ok)
+    }
+
+    if h.Remove(2) != 5 || h.Len() != 3 {
+        t.Errorf("remove: len %v", h.Len())
+    }
+
+    h.Push(5)
+    h.Push(1)
+    h.Set(1, 4)
+
+    for _, want := range []int{1, 2, 3, 4, 5} {
+        if x := h.Pop(); x != want {
+            t.Errorf("pop: want %v, got %v", want, x)
+        }
+    }
+    if _, ok := h.Peek(); ok {
+        t.Errorf("peek: expected empty heap")
+    }
+}
+
+func TestGenericHeapMax(t *testing.T) {
+    h := heap.NewMax[string]()
+    for _, s := range []string{"b", "d", "a", "c"} {
+        h.Push(s)
+    }
+    for _, want := range []string{"d", "c", "b", "a"} {
+        if s := h.Pop(); s != want {
+            t.Errorf("pop: want %v, got %v", want, s)
+        }
+    }
+}
+
+type task struct {
+    name string
+    priority int
+}
+
+func TestGenericHeapLess(t *testing.T) {
+    h := heap.New(func(a, b task) bool {
+        return a.priority < b.priority
+    })
+
+    r := rand.New(rand.NewSource(1))
+    priorities := make([]int, 1000)
+    for i := range priorities {
+        priorities[i] = r.Intn(100)
+        h.Push(task{"t", priorities[i]})
+    }
+    sort.Ints(priorities)
+    for _, want := range priorities {
+        if x := h.Pop(); x.priority != want {
+            t.Fatalf("pop: want %v, got %v", want, x.priority)
+        }
+    }
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+    "math/rand"
+    "testing"
+    "data-structure/heap"
+)
+
+func TestIndexedHeap(t *testing.T) {
+    h := heap.NewIndexedMin[string, int]()
+    h.Push("a", 5)
+    h.Push("b", 3)
+    h.Push("c", 8)
+    h.Push("d", 1)
+
+    if !h.Contains("c") || h.Contains("e") {
+        t.Errorf("contains: wrong answer")
+    }
+
+    // decrease-key and increase-key
+    h.Update("c", 0)
+    h.Update("d", 9)
+    if h.Update("e", 2) {
+        t.Errorf("update: expected missing key to fail")
+    }
+    if key, priority, _ := h.Peek(); key != "c" || priority != 0 {
+        t.Errorf("peek: %v %v", key, priority)
+    }
+
+    if priority, ok := h.Remove("b"); !ok || priority != 3 || h.Contains("b") {
+        t.Errorf("remove: %v %v", priority, ok)
+    }
+    if _, ok := h.Remove("b"); ok {
+        t.Errorf("remove: expected missing key to fail")
+    }
+
+    // Push of a key already in h updates it
+    h.Push("a", 10)
+    if h.Len() != 3 {
+        t.Errorf("len: %v", h.Len())
+    }
+
+    for _, want := range []string{"c", "d", "a"} {
+        if key, _ := h.Pop(); key != want {
+            t.Errorf("pop: want %v, got %v", want, key)
+        }
+    }
+    if _, _, ok := h.Peek(); ok || h.Len() != 0 {
+        t.Errorf("peek: expected empty heap")
+    }
+}
+
+// Dijkstra on a random graph, checked against Bellman-Ford
+func TestIndexedHeapDijkstra(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    const n = 200
+    type edge struct {
+        to, weight int
+    }
+    graph := make([][]edge, n)
+    for i := 0; i < 5 * n; i++ {
+        u := r.Intn(n)
+        graph[u] = append(graph[u], edge{r.Intn(n), r.Intn(100)})
+    }
+
+    const inf = 1 << 30
+    dist := make([]int, n)
+    for i := range dist {
+        dist[i] = inf
+    }
+    dist[0] = 0
+    h := heap.NewIndexedMin[int, int]()
+    h.Push(0, 0)
+    for h.Len() > 0 {
+        u, d := h.Pop()
+        for _, e := range graph[u] {
+            if d + e.weight < dist[e.to] {
+                dist[e.to] = d + e.weight
+                h.Push(e.to, dist[e.to])
+            }
+        }
+    }
+
+    want := make([]int, n)
+    for i := range want {
+        want[i] = inf
+    }
+    want[0] = 0
+    for round := 0; round < n; round++ {
+        for u := range graph {
+            for _, e := range graph[u] {
+                if want[u] != inf && want[u] + e.weight < want[e.to] {
+                    want[e.to] = want[u] + e.weight
+                }
+            }
+        }
+    }
+    for i := range want {
+        if dist[i] != want[i] {
+            t.Fatalf("dist[%v]: want %v, got %v", i, want[i], dist[i])
+        }
+    }
+}
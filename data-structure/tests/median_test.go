@@ -0,0 +1,68 @@
+package tests
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+    "data-structure/heap"
+)
+
+func TestMedian(t *testing.T) {
+    tests := []struct {
+        name string
+        in []int
+        lo, hi int
+    }{
+        {"one", []int{5}, 5, 5},
+        {"two", []int{5, 1}, 1, 5},
+        {"odd", []int{3, 9, 1, 7, 5}, 5, 5},
+        {"even", []int{3, 9, 1, 7}, 3, 7},
+        {"descending", []int{9, 8, 7, 6, 5, 4}, 6, 7},
+        {"duplicates", []int{2, 2, 2, 1}, 2, 2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := heap.NewMedian(lessInt)
+            for _, x := range tt.in {
+                m.Add(x)
+            }
+            lo, hi, ok := m.Median()
+            if !ok || lo != tt.lo || hi != tt.hi || m.Len() != len(tt.in) {
+                t.Fatalf("want %v %v, got %v %v %v", tt.lo, tt.hi, lo, hi, ok)
+            }
+        })
+    }
+
+    if _, _, ok := heap.NewMedian(lessInt).Median(); ok {
+        t.Fatalf("expected no median of nothing")
+    }
+}
+
+func TestMedianRunning(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    m := heap.NewMedian(func(a, b string) bool {
+        return a < b
+    })
+    seen := []string{}
+    for i := 0; i < 500; i++ {
+        x := string(rune('a' + r.Intn(26))) + string(rune('a' + r.Intn(26)))
+        m.Add(x)
+        seen = append(seen, x)
+        sort.Strings(seen)
+        n := len(seen)
+        lo, hi, _ := m.Median()
+        if lo != seen[(n - 1) / 2] || hi != seen[n / 2] {
+            t.Fatalf("after %v: want %v %v, got %v %v", n, seen[(n - 1) / 2], seen[n / 2], lo, hi)
+        }
+    }
+}
+
+func BenchmarkMedian(b *testing.B) {
+    r := rand.New(rand.NewSource(1))
+    m := heap.NewMedian(lessInt)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        m.Add(r.Int())
+        m.Median()
+    }
+}
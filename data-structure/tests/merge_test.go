@@ -0,0 +1,98 @@
+package tests
+
+import (
+    "iter"
+    "math/rand"
+    "reflect"
+    "slices"
+    "sort"
+    "testing"
+    "data-structure/heap"
+)
+
+func seqs(lists ...[]int) []iter.Seq[int] {
+    s := make([]iter.Seq[int], len(lists))
+    for i, list := range lists {
+        s[i] = slices.Values(list)
+    }
+    return s
+}
+
+func TestMerge(t *testing.T) {
+    tests := []struct {
+        name string
+        in [][]int
+        want []int
+    }{
+        {"no sources", nil, nil},
+        {"empty sources", [][]int{{}, {}}, nil},
+        {"one source", [][]int{{1, 2, 3}}, []int{1, 2, 3}},
+        {"interleaved", [][]int{{1, 4, 7}, {2, 5, 8}, {3, 6, 9}}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+        {"uneven", [][]int{{5}, {}, {1, 2, 3, 10}}, []int{1, 2, 3, 5, 10}},
+        {"duplicates", [][]int{{1, 1, 2}, {1, 2}}, []int{1, 1, 1, 2, 2}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := slices.Collect(heap.Merge(lessInt, seqs(tt.in...)...))
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("want %v, got %v", tt.want, got)
+            }
+        })
+    }
+}
+
+type record struct {
+    key int
+    source string
+}
+
+func TestMergeStable(t *testing.T) {
+    a := []record{{1, "a"}, {2, "a"}}
+    b := []record{{1, "b"}, {2, "b"}}
+    got := slices.Collect(heap.Merge(func(x, y record) bool {
+        return x.key < y.key
+    }, slices.Values(a), slices.Values(b)))
+    want := []record{{1, "a"}, {1, "b"}, {2, "a"}, {2, "b"}}
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("want %v, got %v", want, got)
+    }
+}
+
+func TestMergeEarlyStop(t *testing.T) {
+    pulled := 0
+    counting := func(yield func(int) bool) {
+        for i := 0; ; i++ {
+            pulled++
+            if !yield(i) {
+                return
+            }
+        }
+    }
+    got := []int{}
+    for x := range heap.Merge(lessInt, counting, slices.Values([]int{0, 1})) {
+        if len(got) == 5 {
+            break
+        }
+        got = append(got, x)
+    }
+    if !reflect.DeepEqual(got, []int{0, 0, 1, 1, 2}) || pulled > 5 {
+        t.Fatalf("got %v after pulling %v", got, pulled)
+    }
+}
+
+func BenchmarkMerge(b *testing.B) {
+    r := rand.New(rand.NewSource(1))
+    lists := make([][]int, 16)
+    for i := range lists {
+        lists[i] = make([]int, 1000)
+        for j := range lists[i] {
+            lists[i][j] = r.Int()
+        }
+        sort.Ints(lists[i])
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for range heap.Merge(lessInt, seqs(lists...)...) {
+        }
+    }
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+    "context"
+    "math/rand"
+    "testing"
+    "time"
+    "data-structure/timer"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func receive[T any](t *testing.T, c <-chan T) T {
+    t.Helper()
+    select {
+    case x := <-c:
+        return x
+    case <-time.After(time.Second):
+        t.Fatalf("nothing delivered")
+        panic("unreachable")
+    }
+}
+
+func nothing[T any](t *testing.T, c <-chan T) {
+    t.Helper()
+    select {
+    case x := <-c:
+        t.Fatalf("unexpected delivery of %v", x)
+    case <-time.After(20 * time.Millisecond):
+    }
+}
+
+func TestDelayQueue(t *testing.T) {
+    clock := timer.NewFake(epoch)
+    q := timer.NewDelayQueue[string](clock)
+    defer q.Close()
+
+    q.Schedule("a", epoch.Add(3 * time.Second))
+    q.Schedule("b", epoch.Add(time.Second))
+    c := q.Schedule("c", epoch.Add(2 * time.Second))
+    q.Schedule("b2", epoch.Add(time.Second))
+    if !q.Cancel(c) || q.Cancel(c) {
+        t.Fatalf("expected only the first cancel to succeed")
+    }
+    nothing(t, q.C())
+
+    clock.Advance(time.Second)
+    // same due time, in order of scheduling.
+    if x := receive(t, q.C()); x != "b" {
+        t.Fatalf("want b, got %v", x)
+    }
+    if x := receive(t, q.C()); x != "b2" {
+        t.Fatalf("want b2, got %v", x)
+    }
+    nothing(t, q.C())
+
+    // an earlier item scheduled while the queue waits for a.
+    q.Schedule("d", epoch.Add(1500 * time.Millisecond))
+    q.Schedule("now", epoch)
+    if x := receive(t, q.C()); x != "now" {
+        t.Fatalf("want now, got %v", x)
+    }
+    clock.Advance(5 * time.Second)
+    if x := receive(t, q.C()); x != "d" {
+        t.Fatalf("want d, got %v", x)
+    }
+    if x := receive(t, q.C()); x != "a" {
+        t.Fatalf("want a, got %v", x)
+    }
+    if q.Len() != 0 {
+        t.Fatalf("len: %v", q.Len())
+    }
+}
+
+func TestDelayQueueClose(t *testing.T) {
+    q := timer.NewDelayQueue[int](timer.Real)
+    q.Schedule(1, time.Now().Add(time.Hour))
+    q.Close()
+    q.Close()
+    if _, ok := <-q.C(); ok {
+        t.Fatalf("expected C to be closed")
+    }
+}
+
+func TestDelayQueueReal(t *testing.T) {
+    q := timer.NewDelayQueue[int](timer.Real)
+    defer q.Close()
+    start := time.Now()
+    q.Schedule(2, start.Add(20 * time.Millisecond))
+    q.Schedule(1, start.Add(10 * time.Millisecond))
+    if receive(t, q.C()) != 1 || receive(t, q.C()) != 2 {
+        t.Fatalf("wrong order")
+    }
+    if time.Since(start) < 20 * time.Millisecond {
+        t.Fatalf("delivered early")
+    }
+}
+
+// a wheel against a list of deadlines, with timers from one tick to
+// several turns of the top level, some of them stopped.
+func TestWheel(t *testing.T) {
+    const tick = time.Millisecond
+    for _, bits := range []int{1, 3, 6} {
+        r := rand.New(rand.NewSource(int64(bits)))
+        clock := timer.NewFake(epoch)
+        w := timer.NewWheel(clock, tick, bits)
+
+        deadlines := map[int]time.Time{}
+        timers := map[int]*timer.Timer{}
+        fired := map[int]bool{}
+        stopped := map[int]bool{}
+        next := 0
+        add := func() {
+            id := next
+            next++
+            d := time.Duration(1 + r.Intn(1 << (3 * bits + 2))) * tick
+            deadlines[id] = clock.Now().Add(d)
+            timers[id] = w.AfterFunc(d, func() {
+                if fired[id] {
+                    t.Fatalf("bits=%v: timer %v fired twice", bits, id)
+                }
+                fired[id] = true
+            })
+        }
+
+        for i := 0; i < 500; i++ {
+            add()
+        }
+        for step := 0; step < 2000; step++ {
+            if r.Intn(4) == 0 {
+                add()
+            }
+            if r.Intn(10) == 0 {
+                id := r.Intn(next)
+                ok := timers[id].Stop()
+                if ok != (!fired[id] && !stopped[id]) {
+                    t.Fatalf("bits=%v: Stop of timer %v returned %v", bits, id, ok)
+                }
+                if ok {
+                    stopped[id] = true
+                    delete(deadlines, id)
+                }
+            }
+            clock.Advance(time.Duration(r.Intn(8)) * tick)
+            w.Advance()
+
+            now := clock.Now()
+            pending := 0
+            for id, deadline := range deadlines {
+                if fired[id] != !deadline.After(now) {
+                    t.Fatalf("bits=%v: timer %v due %v, fired %v at %v",
+                        bits, id, deadline.Sub(epoch), fired[id], now.Sub(epoch))
+                }
+                if !fired[id] {
+                    pending++
+                }
+            }
+            if w.Len() != pending {
+                t.Fatalf("bits=%v: len %v, want %v", bits, w.Len(), pending)
+            }
+        }
+    }
+}
+
+func TestWheelRun(t *testing.T) {
+    clock := timer.NewFake(epoch)
+    w := timer.NewWheel(clock, 10 * time.Millisecond, 4)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    done := make(chan error)
+    go func() {
+        done <- w.Run(ctx)
+    }()
+
+    fired := make(chan int, 2)
+    w.AfterFunc(50 * time.Millisecond, func() { fired <- 1 })
+    w.AfterFunc(time.Second, func() { fired <- 2 })
+    clock.Advance(100 * time.Millisecond)
+    if x := receive(t, fired); x != 1 {
+        t.Fatalf("want 1, got %v", x)
+    }
+    nothing(t, fired)
+    cancel()
+    if err := <-done; err != context.Canceled {
+        t.Fatalf("expected Run to stop, got %v", err)
+    }
+}
+
+func BenchmarkWheelAfterFunc(b *testing.B) {
+    clock := timer.NewFake(epoch)
+    w := timer.NewWheel(clock, time.Millisecond, 8)
+    r := rand.New(rand.NewSource(1))
+    f := func() {}
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        w.AfterFunc(time.Duration(r.Intn(1 << 20)) * time.Millisecond, f)
+        if i % 64 == 0 {
+            clock.Advance(time.Millisecond)
+            w.Advance()
+        }
+    }
+}
+
+func BenchmarkDelayQueueSchedule(b *testing.B) {
+    q := timer.NewDelayQueue[int](timer.NewFake(epoch))
+    defer q.Close()
+    r := rand.New(rand.NewSource(1))
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        q.Schedule(i, epoch.Add(time.Duration(1 + r.Intn(1 << 20)) * time.Millisecond))
+    }
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+    "math/rand"
+    "reflect"
+    "sort"
+    "strings"
+    "testing"
+    "data-structure/heap"
+)
+
+func TestTopK(t *testing.T) {
+    tests := []struct {
+        name string
+        k int
+        in []int
+        want []int
+    }{
+        {"empty", 3, nil, []int{}},
+        {"zero k", 0, []int{1, 2}, []int{}},
+        {"fewer than k", 5, []int{3, 1, 2}, []int{3, 2, 1}},
+        {"exactly k", 3, []int{2, 3, 1}, []int{3, 2, 1}},
+        {"more than k", 3, []int{5, 1, 9, 3, 7, 2, 8}, []int{9, 8, 7}},
+        {"duplicates", 2, []int{4, 4, 1, 4}, []int{4, 4}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            topK := heap.NewTopK(tt.k, lessInt)
+            for _, x := range tt.in {
+                topK.Add(x)
+            }
+            if got := topK.Items(); !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("want %v, got %v", tt.want, got)
+            }
+            // Items leaves the collector alone.
+            if got := topK.Items(); !reflect.DeepEqual(got, tt.want) {
+                t.Fatalf("second Items: want %v, got %v", tt.want, got)
+            }
+        })
+    }
+}
+
+type wordCount struct {
+    word string
+    count int
+}
+
+func TestTopKStruct(t *testing.T) {
+    // the 2 least frequent words, by reversing less.
+    topK := heap.NewTopK(2, func(a, b wordCount) bool {
+        return a.count > b.count
+    })
+    counts := map[string]int{}
+    for _, w := range strings.Fields("a b c a b a d d d d") {
+        counts[w]++
+    }
+    for w, c := range counts {
+        topK.Add(wordCount{w, c})
+    }
+    want := []wordCount{{"c", 1}, {"b", 2}}
+    if got := topK.Items(); !reflect.DeepEqual(got, want) {
+        t.Fatalf("want %v, got %v", want, got)
+    }
+}
+
+func TestTopKRandom(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    in := make([]int, 10000)
+    topK := heap.NewTopK(100, lessInt)
+    for i := range in {
+        in[i] = r.Int()
+        topK.Add(in[i])
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(in)))
+    if got := topK.Items(); !reflect.DeepEqual(got, in[:100]) {
+        t.Fatalf("wrong top 100")
+    }
+}
+
+func BenchmarkTopK(b *testing.B) {
+    r := rand.New(rand.NewSource(1))
+    topK := heap.NewTopK(100, lessInt)
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        topK.Add(r.Int())
+    }
+}
@@ -0,0 +1,116 @@
+package timer
+
+import (
+    "sync"
+    "time"
+)
+
+// Where DelayQueue & Wheel get the time, so that tests can use a Fake.
+
+// Alarms take a deadline rather than a duration, so that a Fake
+// advanced between reading Now and setting an alarm cannot leave
+// the alarm waiting for a time that has already passed.
+type Clock interface {
+    Now() time.Time
+    At(deadline time.Time) Alarm
+}
+
+type Alarm interface {
+    // C receives the time once the deadline has passed.
+    C() <-chan time.Time
+    // Stop reports whether it stopped the alarm before it went off.
+    Stop() bool
+}
+
+type realClock struct{}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+func (realClock) Now() time.Time {
+    return time.Now()
+}
+
+func (realClock) At(deadline time.Time) Alarm {
+    return realAlarm{time.NewTimer(time.Until(deadline))}
+}
+
+type realAlarm struct {
+    t *time.Timer
+}
+
+func (a realAlarm) C() <-chan time.Time {
+    return a.t.C
+}
+
+func (a realAlarm) Stop() bool {
+    return a.t.Stop()
+}
+
+// A Clock that only moves when told to.
+type Fake struct {
+    mu sync.Mutex
+    now time.Time
+    alarms map[*fakeAlarm]bool
+}
+
+func NewFake(now time.Time) *Fake {
+    return &Fake{now: now, alarms: map[*fakeAlarm]bool{}}
+}
+
+func (f *Fake) Now() time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.now
+}
+
+func (f *Fake) At(deadline time.Time) Alarm {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    a := &fakeAlarm{fake: f, deadline: deadline, c: make(chan time.Time, 1)}
+    if deadline.After(f.now) {
+        f.alarms[a] = true
+    } else {
+        a.c <- f.now
+    }
+    return a
+}
+
+// Advance moves the clock forward by d, setting off every alarm
+// whose deadline has passed.
+func (f *Fake) Advance(d time.Duration) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.now = f.now.Add(d)
+    for a := range f.alarms {
+        if !a.deadline.After(f.now) {
+            delete(f.alarms, a)
+            a.c <- f.now
+        }
+    }
+}
+
+// Alarms returns how many alarms are waiting to go off.
+func (f *Fake) Alarms() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return len(f.alarms)
+}
+
+type fakeAlarm struct {
+    fake *Fake
+    deadline time.Time
+    c chan time.Time
+}
+
+func (a *fakeAlarm) C() <-chan time.Time {
+    return a.c
+}
+
+func (a *fakeAlarm) Stop() bool {
+    a.fake.mu.Lock()
+    defer a.fake.mu.Unlock()
+    pending := a.fake.alarms[a]
+    delete(a.fake.alarms, a)
+    return pending
+}
@@ -0,0 +1,140 @@
+package timer
+
+import (
+    "sync"
+    "time"
+    "data-structure/heap"
+)
+
+// Items become available on C() at their due time, in order of due
+// time, and in order of scheduling for the same due time.
+
+type due struct {
+    at time.Time
+    seq uint64
+}
+
+func earlier(a, b due) bool {
+    if a.at.Equal(b.at) {
+        return a.seq < b.seq
+    }
+    return a.at.Before(b.at)
+}
+
+type DelayQueue[T any] struct {
+    clock Clock
+    mu sync.Mutex
+    pending *heap.Indexed[uint64, due]
+    items map[uint64]T
+    seq uint64
+    wake chan struct{} // the earliest due time may have changed
+    out chan T
+    done chan struct{}
+    closeOnce sync.Once
+}
+
+// NewDelayQueue starts a queue, which runs until Close.
+func NewDelayQueue[T any](clock Clock) *DelayQueue[T] {
+    q := &DelayQueue[T]{
+        clock: clock,
+        pending: heap.NewIndexed[uint64](earlier),
+        items: map[uint64]T{},
+        wake: make(chan struct{}, 1),
+        out: make(chan T),
+        done: make(chan struct{}),
+    }
+    go q.run()
+    return q
+}
+
+// C delivers each item once it is due. It is closed by Close.
+func (q *DelayQueue[T]) C() <-chan T {
+    return q.out
+}
+
+// Schedule makes item available at the time at, and returns an id for Cancel.
+func (q *DelayQueue[T]) Schedule(item T, at time.Time) uint64 {
+    q.mu.Lock()
+    q.seq++
+    id := q.seq
+    q.items[id] = item
+    q.pending.Push(id, due{at, id})
+    q.mu.Unlock()
+    q.poke()
+    return id
+}
+
+// Cancel reports whether it removed the item with id before it was
+// taken off the queue for delivery.
+func (q *DelayQueue[T]) Cancel(id uint64) bool {
+    q.mu.Lock()
+    _, ok := q.pending.Remove(id)
+    delete(q.items, id)
+    q.mu.Unlock()
+    if ok {
+        q.poke()
+    }
+    return ok
+}
+
+// Len returns how many items are waiting for their due time.
+func (q *DelayQueue[T]) Len() int {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return q.pending.Len()
+}
+
+// Close stops the queue, dropping items not yet delivered, and closes C.
+func (q *DelayQueue[T]) Close() {
+    q.closeOnce.Do(func() {
+        close(q.done)
+    })
+}
+
+func (q *DelayQueue[T]) poke() {
+    select {
+    case q.wake <- struct{}{}:
+    default:
+    }
+}
+
+func (q *DelayQueue[T]) run() {
+    defer close(q.out)
+    for {
+        q.mu.Lock()
+        id, next, ok := q.pending.Peek()
+        if !ok {
+            q.mu.Unlock()
+            select {
+            case <-q.wake:
+                continue
+            case <-q.done:
+                return
+            }
+        }
+
+        if !next.at.After(q.clock.Now()) {
+            q.pending.Pop()
+            item := q.items[id]
+            delete(q.items, id)
+            q.mu.Unlock()
+            select {
+            case q.out <- item:
+            case <-q.done:
+                return
+            }
+            continue
+        }
+        q.mu.Unlock()
+
+        alarm := q.clock.At(next.at)
+        select {
+        case <-alarm.C():
+        case <-q.wake:
+            alarm.Stop()
+        case <-q.done:
+            alarm.Stop()
+            return
+        }
+    }
+}
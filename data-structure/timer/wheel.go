@@ -0,0 +1,198 @@
+package timer
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// Hierarchical timing wheel: O(1) to add or stop a timer, however
+// many there are, at the cost of firing only on tick boundaries.
+//
+// Level 0 has one slot per tick; each slot of level l covers a whole
+// turn of level l-1. A timer goes in the lowest level that reaches
+// its expiry, and moves down a level each time the level below turns
+// over onto its slot, as in the Linux kernel's timer wheel. Levels
+// are added as longer timers need them.
+
+// A timer started by Wheel.AfterFunc.
+type Timer struct {
+    wheel *Wheel
+    expiry uint64 // in ticks since the wheel started
+    f func()
+    slot *slot
+    prev, next *Timer
+}
+
+// Stop reports whether it stopped t before t fired.
+func (t *Timer) Stop() bool {
+    w := t.wheel
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if t.slot == nil {
+        return false
+    }
+    t.slot.remove(t)
+    w.count--
+    return true
+}
+
+type slot struct {
+    head *Timer
+}
+
+func (s *slot) add(t *Timer) {
+    t.slot = s
+    t.prev = nil
+    t.next = s.head
+    if s.head != nil {
+        s.head.prev = t
+    }
+    s.head = t
+}
+
+func (s *slot) remove(t *Timer) {
+    if t.prev == nil {
+        s.head = t.next
+    } else {
+        t.prev.next = t.next
+    }
+    if t.next != nil {
+        t.next.prev = t.prev
+    }
+    t.slot, t.prev, t.next = nil, nil, nil
+}
+
+// take empties s, returning its timers as a list.
+func (s *slot) take() *Timer {
+    head := s.head
+    s.head = nil
+    return head
+}
+
+type Wheel struct {
+    clock Clock
+    tick time.Duration
+    bits uint // each level has 1 << bits slots
+    start time.Time
+    mu sync.Mutex
+    now uint64 // ticks done
+    levels [][]slot
+    count int
+}
+
+// NewWheel returns a wheel that moves in steps of tick, with 1 << bits
+// slots per level. It panics unless tick > 0 and 1 <= bits <= 16.
+func NewWheel(clock Clock, tick time.Duration, bits int) *Wheel {
+    if tick <= 0 || bits < 1 || bits > 16 {
+        panic("timer: bad tick or bits for NewWheel")
+    }
+    w := &Wheel{clock: clock, tick: tick, bits: uint(bits), start: clock.Now()}
+    w.levels = [][]slot{make([]slot, 1 << w.bits)}
+    return w
+}
+
+// Len returns how many timers have yet to fire.
+func (w *Wheel) Len() int {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.count
+}
+
+// AfterFunc calls f, from Advance, on the first tick at least d from now.
+func (w *Wheel) AfterFunc(d time.Duration, f func()) *Timer {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    at := w.clock.Now().Add(d).Sub(w.start)
+    expiry := uint64(0)
+    if at > 0 {
+        expiry = uint64((at + w.tick - 1) / w.tick)
+    }
+    if expiry <= w.now {
+        expiry = w.now + 1
+    }
+    t := &Timer{wheel: w, expiry: expiry, f: f}
+    w.place(t)
+    w.count++
+    return t
+}
+
+func (w *Wheel) place(t *Timer) {
+    mask := uint64(1) << w.bits - 1
+    delta := t.expiry - w.now
+    level := 0
+    for delta >> (w.bits * uint(level + 1)) != 0 {
+        level++
+    }
+    for len(w.levels) <= level {
+        w.levels = append(w.levels, make([]slot, 1 << w.bits))
+    }
+    index := t.expiry >> (w.bits * uint(level)) & mask
+    w.levels[level][index].add(t)
+}
+
+// Advance does every tick up to the clock's time, and calls the
+// functions of the timers that fire, in the caller's goroutine.
+// It returns how many fired.
+func (w *Wheel) Advance() int {
+    w.mu.Lock()
+    target := uint64(0)
+    if elapsed := w.clock.Now().Sub(w.start); elapsed > 0 {
+        target = uint64(elapsed / w.tick)
+    }
+    var fired []func()
+    for w.now < target {
+        w.now++
+        w.cascade()
+        for t := w.levels[0][w.now & (uint64(1) << w.bits - 1)].take(); t != nil; {
+            next := t.next
+            t.slot, t.prev, t.next = nil, nil, nil
+            fired = append(fired, t.f)
+            t = next
+        }
+    }
+    w.count -= len(fired)
+    w.mu.Unlock()
+
+    for _, f := range fired {
+        f()
+    }
+    return len(fired)
+}
+
+// When a level turns over, move the timers in the next level's
+// current slot down to where they now belong.
+func (w *Wheel) cascade() {
+    mask := uint64(1) << w.bits - 1
+    for level := 1; level < len(w.levels); level++ {
+        shift := w.bits * uint(level)
+        if w.now & (uint64(1) << shift - 1) != 0 {
+            return
+        }
+        index := w.now >> shift & mask
+        for t := w.levels[level][index].take(); t != nil; {
+            next := t.next
+            t.slot, t.prev, t.next = nil, nil, nil
+            w.place(t)
+            t = next
+        }
+    }
+}
+
+// Run calls Advance at every tick until ctx is done.
+func (w *Wheel) Run(ctx context.Context) error {
+    for {
+        w.mu.Lock()
+        next := w.start.Add(time.Duration(w.now + 1) * w.tick)
+        w.mu.Unlock()
+
+        alarm := w.clock.At(next)
+        select {
+        case <-alarm.C():
+            w.Advance()
+        case <-ctx.Done():
+            alarm.Stop()
+            return ctx.Err()
+        }
+    }
+}
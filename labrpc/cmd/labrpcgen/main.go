@@ -0,0 +1,266 @@
+// labrpcgen emits a typed client for a labrpc service, so that
+// renaming a handler or changing its argument or reply type breaks
+// the build instead of a test run. put a directive next to the
+// service:
+//
+//    //go:generate go run lab-rpc/cmd/labrpcgen -type KVServer
+//
+// and `go generate` writes kvserver_client.go into the same package,
+// with a KVServerClient whose methods mirror KVServer's handlers:
+//
+//    client := MakeKVServerClient(clientEnd)
+//    reply, err := client.Get(ctx, GetArgs{Key: "x"})
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/format"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+const labrpcPath = "lab-rpc/labrpc"
+
+func main() {
+    typeName := flag.String("type", "", "name of the service struct; required")
+    output := flag.String("output", "", "output file name; default <type>_client.go")
+    dir := flag.String("dir", ".", "directory of the service's package")
+    flag.Parse()
+
+    if *typeName == "" {
+        flag.Usage()
+        os.Exit(2)
+    }
+    if *output == "" {
+        *output = strings.ToLower(*typeName) + "_client.go"
+    }
+
+    fset := token.NewFileSet()
+    files, err := parsePackage(fset, *dir, *output)
+    if err != nil {
+        log.Fatalf("labrpcgen: %v", err)
+    }
+
+    src, err := generate(files, *typeName)
+    if err != nil {
+        log.Fatalf("labrpcgen: %v", err)
+    }
+
+    if err := os.WriteFile(filepath.Join(*dir, *output), src, 0644); err != nil {
+        log.Fatalf("labrpcgen: %v", err)
+    }
+}
+
+// the package's non-test files, other than an earlier output.
+func parsePackage(fset *token.FileSet, dir string, output string) ([]*ast.File, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    files := []*ast.File{}
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || !strings.HasSuffix(name, ".go") ||
+            strings.HasSuffix(name, "_test.go") || name == output {
+            continue
+        }
+        file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, file)
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("no Go files in %v", dir)
+    }
+    return files, nil
+}
+
+// a handler, in one of the shapes labrpc.MakeService accepts.
+type handler struct {
+    name        string
+    withContext bool
+    withError   bool
+    argsType    string
+    replyType   string // what the reply argument points to
+}
+
+func generate(files []*ast.File, typeName string) ([]byte, error) {
+    pkgName := files[0].Name.Name
+    found := false
+    handlers := []handler{}
+    imports := map[string]string{} // path -> local name, "" for the default
+
+    for _, file := range files {
+        for _, decl := range file.Decls {
+            switch decl := decl.(type) {
+            case *ast.GenDecl:
+                for _, spec := range decl.Specs {
+                    if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == typeName {
+                        found = true
+                    }
+                }
+            case *ast.FuncDecl:
+                h, ok := handlerOf(decl, typeName)
+                if !ok {
+                    continue
+                }
+                handlers = append(handlers, h)
+                for _, param := range decl.Type.Params.List {
+                    if err := addImports(imports, file, param.Type); err != nil {
+                        return nil, err
+                    }
+                }
+            }
+        }
+    }
+
+    if !found {
+        return nil, fmt.Errorf("type %v not found in package %v", typeName, pkgName)
+    }
+    if len(handlers) == 0 {
+        return nil, fmt.Errorf("type %v has no handlers", typeName)
+    }
+    sort.Slice(handlers, func(i, j int) bool { return handlers[i].name < handlers[j].name })
+
+    imports["context"] = ""
+    imports[labrpcPath] = ""
+
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "// Code generated by labrpcgen -type %v; DO NOT EDIT.\n\n", typeName)
+    fmt.Fprintf(&buf, "package %v\n\n", pkgName)
+
+    paths := []string{}
+    for path := range imports {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+    fmt.Fprintf(&buf, "import (\n")
+    for _, path := range paths {
+        fmt.Fprintf(&buf, "%v %q\n", imports[path], path)
+    }
+    fmt.Fprintf(&buf, ")\n\n")
+
+    client := typeName + "Client"
+    fmt.Fprintf(&buf, "// a typed client for the %v service.\n", typeName)
+    fmt.Fprintf(&buf, "type %v struct {\nclientEnd *labrpc.ClientEnd\n}\n\n", client)
+    fmt.Fprintf(&buf, "func Make%v(clientEnd *labrpc.ClientEnd) *%v {\n", client, client)
+    fmt.Fprintf(&buf, "return &%v{clientEnd}\n}\n\n", client)
+
+    for _, h := range handlers {
+        // fails to compile if the handler is renamed or its types change.
+        params := []string{"*" + typeName}
+        if h.withContext {
+            params = append(params, "context.Context")
+        }
+        params = append(params, h.argsType, "*"+h.replyType)
+        result := ""
+        if h.withError {
+            result = " error"
+        }
+        fmt.Fprintf(&buf, "var _ func(%v)%v = (*%v).%v\n\n", strings.Join(params, ", "), result, typeName, h.name)
+
+        fmt.Fprintf(&buf, "func (client *%v) %v(ctx context.Context, args %v) (%v, error) {\n", client, h.name, h.argsType, h.replyType)
+        fmt.Fprintf(&buf, "var reply %v\n", h.replyType)
+        fmt.Fprintf(&buf, "err := client.clientEnd.Invoke(ctx, %v, args, &reply)\n", strconv.Quote(typeName+"."+h.name))
+        fmt.Fprintf(&buf, "return reply, err\n}\n\n")
+    }
+
+    src, err := format.Source(buf.Bytes())
+    if err != nil {
+        return nil, fmt.Errorf("formatting generated code: %v", err)
+    }
+    // no tabs, like the rest of the repository.
+    return bytes.ReplaceAll(src, []byte("\t"), []byte("    ")), nil
+}
+
+func handlerOf(decl *ast.FuncDecl, typeName string) (handler, bool) {
+    h := handler{}
+    if decl.Recv == nil || len(decl.Recv.List) != 1 || !decl.Name.IsExported() {
+        return h, false
+    }
+    recv := decl.Recv.List[0].Type
+    if star, ok := recv.(*ast.StarExpr); ok {
+        recv = star.X
+    }
+    if ident, ok := recv.(*ast.Ident); !ok || ident.Name != typeName {
+        return h, false
+    }
+    h.name = decl.Name.Name
+
+    params := []ast.Expr{}
+    for _, field := range decl.Type.Params.List {
+        n := len(field.Names)
+        if n == 0 {
+            n = 1
+        }
+        for i := 0; i < n; i++ {
+            params = append(params, field.Type)
+        }
+    }
+
+    if len(params) == 3 && types.ExprString(params[0]) == "context.Context" {
+        h.withContext = true
+        params = params[1:]
+    }
+    if len(params) != 2 {
+        return h, false
+    }
+    reply, ok := params[1].(*ast.StarExpr)
+    if !ok {
+        return h, false
+    }
+    h.argsType = types.ExprString(params[0])
+    h.replyType = types.ExprString(reply.X)
+
+    if results := decl.Type.Results; results != nil && len(results.List) > 0 {
+        if len(results.List) != 1 || len(results.List[0].Names) > 1 ||
+            types.ExprString(results.List[0].Type) != "error" {
+            return h, false
+        }
+        h.withError = true
+    }
+
+    return h, true
+}
+
+// record the imports of file that expr refers to.
+func addImports(imports map[string]string, file *ast.File, expr ast.Expr) error {
+    var err error
+    ast.Inspect(expr, func(node ast.Node) bool {
+        selector, ok := node.(*ast.SelectorExpr)
+        if !ok {
+            return true
+        }
+        ident, ok := selector.X.(*ast.Ident)
+        if !ok {
+            return true
+        }
+        for _, spec := range file.Imports {
+            path, _ := strconv.Unquote(spec.Path.Value)
+            if spec.Name != nil {
+                if spec.Name.Name == ident.Name {
+                    imports[path] = spec.Name.Name
+                    return false
+                }
+            } else if filepath.Base(path) == ident.Name {
+                imports[path] = ""
+                return false
+            }
+        }
+        err = fmt.Errorf("cannot find import for %v.%v", ident.Name, selector.Sel.Name)
+        return false
+    })
+    return err
+}
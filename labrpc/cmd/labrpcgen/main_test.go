@@ -0,0 +1,96 @@
+package main
+
+import (
+    "go/ast"
+    "go/importer"
+    "go/parser"
+    "go/token"
+    "go/types"
+    "strings"
+    "testing"
+)
+
+func TestGenerate(t *testing.T) {
+    fset := token.NewFileSet()
+    files, err := parsePackage(fset, "testdata/kv", "kvserver_client.go")
+    if err != nil {
+        t.Fatalf("parse: %v", err)
+    }
+
+    src, err := generate(files, "KVServer")
+    if err != nil {
+        t.Fatalf("generate: %v", err)
+    }
+    if strings.Contains(string(src), "\t") {
+        t.Fatalf("generated code contains tabs")
+    }
+
+    for _, want := range []string{
+        "func (client *KVServerClient) Get(ctx context.Context, args GetArgs) (GetReply, error)",
+        "func (client *KVServerClient) Put(ctx context.Context, args *PutArgs) (bool, error)",
+        "func (client *KVServerClient) Size(ctx context.Context, args int) (int, error)",
+        `client.clientEnd.Invoke(ctx, "KVServer.Get", args, &reply)`,
+        "var _ func(*KVServer, context.Context, *PutArgs, *bool) error = (*KVServer).Put",
+    } {
+        if !strings.Contains(string(src), want) {
+            t.Fatalf("generated code lacks %q:\n%s", want, src)
+        }
+    }
+    for _, unwanted := range []string{"Kill", "lock"} {
+        if strings.Contains(string(src), ") "+unwanted+"(") {
+            t.Fatalf("generated a client method for non-handler %v", unwanted)
+        }
+    }
+
+    // the generated code must type-check alongside the service.
+    generated, err := parser.ParseFile(fset, "kvserver_client.go", src, 0)
+    if err != nil {
+        t.Fatalf("parse generated code: %v", err)
+    }
+    config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+    if _, err := config.Check("kv", fset, append(files, generated), nil); err != nil {
+        t.Fatalf("generated code does not type-check: %v\n%s", err, src)
+    }
+}
+
+func TestGenerateRenamedHandler(t *testing.T) {
+    fset := token.NewFileSet()
+    files, err := parsePackage(fset, "testdata/kv", "kvserver_client.go")
+    if err != nil {
+        t.Fatalf("parse: %v", err)
+    }
+
+    src, err := generate(files, "KVServer")
+    if err != nil {
+        t.Fatalf("generate: %v", err)
+    }
+
+    // rename Get in the service after generating the client.
+    for _, file := range files {
+        for _, decl := range file.Decls {
+            if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "Get" {
+                fn.Name.Name = "Fetch"
+            }
+        }
+    }
+
+    generated, err := parser.ParseFile(fset, "kvserver_client.go", src, 0)
+    if err != nil {
+        t.Fatalf("parse generated code: %v", err)
+    }
+    config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+    if _, err := config.Check("kv", fset, append(files, generated), nil); err == nil {
+        t.Fatalf("expected stale client to fail to compile after renaming a handler")
+    }
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+    fset := token.NewFileSet()
+    files, err := parsePackage(fset, "testdata/kv", "kvserver_client.go")
+    if err != nil {
+        t.Fatalf("parse: %v", err)
+    }
+    if _, err := generate(files, "NoSuchServer"); err == nil {
+        t.Fatalf("expected an error for an unknown type")
+    }
+}
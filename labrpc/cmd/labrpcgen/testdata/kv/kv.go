@@ -0,0 +1,57 @@
+package kv
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+//go:generate go run lab-rpc/cmd/labrpcgen -type KVServer
+
+type GetArgs struct {
+    Key string
+}
+
+type GetReply struct {
+    Value string
+}
+
+type PutArgs struct {
+    Key   string
+    Value string
+    When  time.Duration
+}
+
+type KVServer struct {
+    mu   sync.Mutex
+    data map[string]string
+}
+
+func (kv *KVServer) Get(args GetArgs, reply *GetReply) {
+    kv.mu.Lock()
+    defer kv.mu.Unlock()
+    reply.Value = kv.data[args.Key]
+}
+
+func (kv *KVServer) Put(ctx context.Context, args *PutArgs, reply *bool) error {
+    kv.mu.Lock()
+    defer kv.mu.Unlock()
+    kv.data[args.Key] = args.Value
+    *reply = true
+    return nil
+}
+
+func (kv *KVServer) Size(args int, reply *int) error {
+    kv.mu.Lock()
+    defer kv.mu.Unlock()
+    *reply = len(kv.data)
+    return nil
+}
+
+// not a handler: wrong shape.
+func (kv *KVServer) Kill() {
+}
+
+// not a handler: unexported.
+func (kv *KVServer) lock(args int, reply *int) {
+}
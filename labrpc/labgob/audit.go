@@ -0,0 +1,102 @@
+package labgob
+
+//
+// a report of interface registrations, for CI: every name given
+// to Register() or RegisterName(), and every type that turned up
+// in an interface field at encode time without one, with where
+// it was first seen:
+//
+//    func TestMain(m *testing.M) {
+//        code := m.Run()
+//        if audit := labgob.AuditRegistrations(); len(audit.Missing) > 0 {
+//            fmt.Print(audit)
+//            code = 1
+//        }
+//        os.Exit(code)
+//    }
+//
+// only registrations made through labgob are known; a type given
+// straight to gob.Register() is reported as missing.
+//
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+)
+
+type Registration struct {
+    Name    string
+    Type    reflect.Type
+    Builtin bool // one of the basic types labgob registers itself
+}
+
+type MissingRegistration struct {
+    Type reflect.Type
+    Path string // e.g. "AppendEntriesArgs.Entries[3].Command"
+}
+
+type RegistrationAudit struct {
+    Registered []Registration        // sorted by Name
+    Missing    []MissingRegistration // sorted by Type
+}
+
+// types found unregistered by Encode, and the
+// first path each was seen at. a type is dropped
+// once it is registered.
+var missing = map[reflect.Type]string{}
+
+func recordMissing(root reflect.Type, problem *CheckError) {
+    if problem.Problem != UnregisteredInterface {
+        return
+    }
+    where := typeLabel(root)
+    if problem.Path != where {
+        where += "." + problem.Path
+    }
+
+    typesMu.Lock()
+    defer typesMu.Unlock()
+    if _, ok := missing[problem.Type]; !ok {
+        missing[problem.Type] = where
+    }
+}
+
+func AuditRegistrations() RegistrationAudit {
+    typesMu.Lock()
+    defer typesMu.Unlock()
+
+    audit := RegistrationAudit{}
+    for name, t := range nameToType {
+        audit.Registered = append(audit.Registered, Registration{name, t, builtinNames[name]})
+    }
+    sort.Slice(audit.Registered, func(i, j int) bool {
+        return audit.Registered[i].Name < audit.Registered[j].Name
+    })
+
+    for t, path := range missing {
+        audit.Missing = append(audit.Missing, MissingRegistration{t, path})
+    }
+    sort.Slice(audit.Missing, func(i, j int) bool {
+        return audit.Missing[i].Type.String() < audit.Missing[j].Type.String()
+    })
+    return audit
+}
+
+func (audit RegistrationAudit) String() string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "labgob: %d registered types\n", len(audit.Registered))
+    for _, registration := range audit.Registered {
+        if !registration.Builtin {
+            fmt.Fprintf(&b, "    %v (%v)\n", registration.Name, registration.Type)
+        }
+    }
+    if len(audit.Missing) > 0 {
+        fmt.Fprintf(&b, "labgob: %d types sent in interface fields without Register()\n", len(audit.Missing))
+        for _, m := range audit.Missing {
+            fmt.Fprintf(&b, "    %v, first at %v\n", m.Type, m.Path)
+        }
+    }
+    return b.String()
+}
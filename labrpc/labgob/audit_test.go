@@ -0,0 +1,104 @@
+package labgob
+
+import (
+    "bytes"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+type AuditArgs struct {
+    Entries []WalkEntry
+}
+
+type AuditCommand struct {
+    Key string
+}
+
+type AuditLaterCommand struct {
+    Key string
+}
+
+func findMissing(audit RegistrationAudit, t reflect.Type) (MissingRegistration, bool) {
+    for _, m := range audit.Missing {
+        if m.Type == t {
+            return m, true
+        }
+    }
+    return MissingRegistration{}, false
+}
+
+func TestAuditMissing(t *testing.T) {
+    encoder := NewEncoder(new(bytes.Buffer))
+    args := AuditArgs{Entries: []WalkEntry{{Term: 1, Command: AuditCommand{"x"}}}}
+    if err := encoder.Encode(args); err == nil {
+        t.Fatalf("expected gob to refuse an unregistered type")
+    }
+    if encoder.ErrorCount() != 1 {
+        t.Fatalf("expected %d, got %d", 1, encoder.ErrorCount())
+    }
+
+    audit := AuditRegistrations()
+    m, ok := findMissing(audit, reflect.TypeOf(AuditCommand{}))
+    if !ok {
+        t.Fatalf("expected AuditCommand to be missing, got %v", audit)
+    }
+    if m.Path != "AuditArgs.Entries[0].Command" {
+        t.Fatalf("expected path AuditArgs.Entries[0].Command, got %v", m.Path)
+    }
+    if !strings.Contains(audit.String(), "labgob.AuditCommand, first at AuditArgs.Entries[0].Command") {
+        t.Fatalf("expected the report to list AuditCommand, got:\n%v", audit)
+    }
+}
+
+// forget a registration made by a test, so that the test can run
+// again; gob keeps its own, which labgob's checks don't consult.
+func unregister(t *testing.T, name string) {
+    t.Cleanup(func() {
+        typesMu.Lock()
+        defer typesMu.Unlock()
+        delete(typeToName, nameToType[name])
+        delete(nameToType, name)
+    })
+}
+
+func TestAuditRegisteredLater(t *testing.T) {
+    NewEncoder(new(bytes.Buffer)).Encode(WalkEntry{Command: AuditLaterCommand{}})
+    if _, ok := findMissing(AuditRegistrations(), reflect.TypeOf(AuditLaterCommand{})); !ok {
+        t.Fatalf("expected AuditLaterCommand to be missing")
+    }
+
+    RegisterName("later", AuditLaterCommand{})
+    unregister(t, "later")
+    if _, ok := missing[reflect.TypeOf(AuditLaterCommand{})]; ok {
+        t.Fatalf("expected registering AuditLaterCommand to drop it from the missing types")
+    }
+    audit := AuditRegistrations()
+    if _, ok := findMissing(audit, reflect.TypeOf(AuditLaterCommand{})); ok {
+        t.Fatalf("expected AuditLaterCommand to be registered now")
+    }
+
+    found := false
+    for _, registration := range audit.Registered {
+        switch registration.Name {
+        case "later":
+            found = registration.Type == reflect.TypeOf(AuditLaterCommand{}) && !registration.Builtin
+        case "int", "string":
+            if !registration.Builtin {
+                t.Fatalf("expected %v to be builtin", registration.Name)
+            }
+        }
+    }
+    if !found {
+        t.Fatalf("expected later in the registered types, got %v", audit)
+    }
+}
+
+func TestAuditRegistered(t *testing.T) {
+    Register(KeyCommand{})
+    var buf bytes.Buffer
+    encoder := NewEncoder(&buf)
+    if err := encoder.Encode(WalkEntry{Command: KeyCommand{Key: "k"}}); err != nil || encoder.ErrorCount() != 0 {
+        t.Fatalf("expected a registered type to encode cleanly, got %v", err)
+    }
+}
@@ -0,0 +1,167 @@
+package labgob
+
+//
+// labgob's checks for lower-case fields and decoding into
+// non-default values apply whatever the wire format. a Codec
+// supplies the format; gob is the default, and JSON and MsgPack
+// are built in:
+//
+//    encoder := labgob.NewCodecEncoder(labgob.MsgPack, w)
+//    decoder := labgob.NewCodecDecoder(labgob.MsgPack, r)
+//
+// a Codec is also known by its Name(), so that the two ends of a
+// real connection can agree on one; see LookupCodec().
+//
+
+import (
+    "encoding/gob"
+    "encoding/json"
+    "io"
+    "reflect"
+    "sync"
+)
+
+type Encoder interface {
+    Encode(e interface{}) error
+}
+
+type Decoder interface {
+    Decode(e interface{}) error
+}
+
+type Codec interface {
+    Name() string
+    NewEncoder(w io.Writer) Encoder
+    NewDecoder(r io.Reader) Decoder
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string                   { return "gob" }
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+// values in interface fields, such as a Raft log entry's Command,
+// come back as JSON's generic map[string]interface{}, float64 &c,
+// not as the types given to Register().
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                   { return "json" }
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+var (
+    Gob     Codec = gobCodec{}
+    JSON    Codec = jsonCodec{}
+    MsgPack Codec = msgpackCodec{}
+)
+
+var codecMu sync.Mutex
+var codecs = map[string]Codec{
+    Gob.Name():     Gob,
+    JSON.Name():    JSON,
+    MsgPack.Name(): MsgPack,
+}
+
+// make codec known to LookupCodec(), replacing any
+// earlier one with the same name.
+func RegisterCodec(codec Codec) {
+    codecMu.Lock()
+    defer codecMu.Unlock()
+    codecs[codec.Name()] = codec
+}
+
+// the codec with the given name. "" means gob.
+func LookupCodec(name string) (Codec, bool) {
+    if name == "" {
+        return Gob, true
+    }
+    codecMu.Lock()
+    defer codecMu.Unlock()
+    codec, ok := codecs[name]
+    return codec, ok
+}
+
+func NewCodecEncoder(codec Codec, w io.Writer) *LabEncoder {
+    enc := &LabEncoder{}
+    enc.enc = codec.NewEncoder(w)
+    enc.checker = makeChecker()
+    return enc
+}
+
+func NewCodecDecoder(codec Codec, r io.Reader) *LabDecoder {
+    dec := &LabDecoder{}
+    dec.dec = codec.NewDecoder(r)
+    dec.checker = makeChecker()
+    return dec
+}
+
+//
+// names of the concrete types that may travel in interface
+// fields, for codecs other than gob, which keeps its own.
+//
+
+var typesMu sync.Mutex
+var nameToType = map[string]reflect.Type{}
+var typeToName = map[reflect.Type]string{}
+var builtinNames = map[string]bool{} // registered by labgob itself
+
+func registerType(name string, value interface{}) {
+    t := reflect.TypeOf(value)
+    typesMu.Lock()
+    defer typesMu.Unlock()
+    nameToType[name] = t
+    typeToName[t] = name
+    delete(missing, t)
+}
+
+func registeredType(name string) (reflect.Type, bool) {
+    typesMu.Lock()
+    defer typesMu.Unlock()
+    t, ok := nameToType[name]
+    return t, ok
+}
+
+func registeredName(t reflect.Type) (string, bool) {
+    typesMu.Lock()
+    defer typesMu.Unlock()
+    name, ok := typeToName[t]
+    return name, ok
+}
+
+// the name gob.Register() would use.
+func typeName(value interface{}) string {
+    rt := reflect.TypeOf(value)
+    name := rt.String()
+
+    star := ""
+    if rt.Name() == "" && rt.Kind() == reflect.Ptr {
+        star = "*"
+        rt = rt.Elem()
+    }
+    if rt.Name() != "" {
+        if rt.PkgPath() == "" {
+            name = star + rt.Name()
+        } else {
+            name = star + rt.PkgPath() + "." + rt.Name()
+        }
+    }
+    return name
+}
+
+// like gob, allow the basic types in interfaces without
+// registration.
+func init() {
+    for _, value := range []interface{}{
+        false,
+        int(0), int8(0), int16(0), int32(0), int64(0),
+        uint(0), uint8(0), uint16(0), uint32(0), uint64(0), uintptr(0),
+        float32(0), float64(0),
+        "",
+        []byte(nil), []int(nil), []string(nil), []interface{}(nil),
+        map[string]int(nil), map[string]string(nil), map[string]interface{}(nil),
+    } {
+        registerType(typeName(value), value)
+        builtinNames[typeName(value)] = true
+    }
+}
@@ -0,0 +1,313 @@
+package labgob
+
+import (
+    "bytes"
+    "math"
+    "reflect"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+type LogEntry struct {
+    Term    int
+    Command interface{}
+}
+
+type AppendEntriesArgs struct {
+    Term         int
+    LeaderId     int
+    PrevLogIndex int
+    PrevLogTerm  int64
+    Entries      []LogEntry
+    LeaderCommit int
+}
+
+type EpsilonStruct struct {
+    Value string
+}
+
+type DeltaStruct struct {
+    Small   int8
+    Neg     int
+    Big     uint64
+    Ratio   float64
+    Half    float32
+    Short   string
+    Long    string
+    Data    []byte
+    Hash    [4]byte
+    Tags    []string
+    Counts  map[string]int
+    Next    *DeltaStruct
+    Nothing *DeltaStruct
+    Any     interface{}
+}
+
+func codecRoundTrip(t *testing.T, codec Codec, in interface{}, out interface{}) {
+    var buf bytes.Buffer
+    if err := NewCodecEncoder(codec, &buf).Encode(in); err != nil {
+        t.Fatalf("%v: encode: %v", codec.Name(), err)
+    }
+    if err := NewCodecDecoder(codec, &buf).Decode(out); err != nil {
+        t.Fatalf("%v: decode: %v", codec.Name(), err)
+    }
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+    Register(AlphaStruct{})
+
+    in := DeltaStruct{
+        Small:  -100,
+        Neg:    -1 << 40,
+        Big:    math.MaxUint64,
+        Ratio:  3.25,
+        Half:   0.5,
+        Short:  "hi",
+        Long:   strings.Repeat("x", 300),
+        Data:   []byte{0, 1, 2, 255},
+        Hash:   [4]byte{9, 8, 7, 6},
+        Tags:   []string{"a", "", "c"},
+        Counts: map[string]int{"one": 1, "million": 1000000},
+        Next:   &DeltaStruct{Short: "next", Neg: -5},
+        Any:    AlphaStruct{IntKey: 1, StringVal: "v"},
+    }
+
+    for _, codec := range []Codec{Gob, JSON, MsgPack} {
+        var out DeltaStruct
+        codecRoundTrip(t, codec, in, &out)
+        if codec == JSON {
+            // JSON has no way to say which type was in Any.
+            out.Any = in.Any
+        }
+        if !reflect.DeepEqual(in, out) {
+            t.Errorf("%v: expected %+v, got %+v", codec.Name(), in, out)
+        }
+    }
+}
+
+func TestCodecInterfaces(t *testing.T) {
+    Register(AlphaStruct{})
+    Register(&EpsilonStruct{})
+
+    in := AppendEntriesArgs{
+        Term:     3,
+        LeaderId: 1,
+        Entries: []LogEntry{
+            {1, 42},
+            {2, "put x"},
+            {3, AlphaStruct{IntKey: 7}},
+            {3, &EpsilonStruct{"e"}},
+            {4, AlphaStruct{IntKey: 9}},
+            {3, nil},
+        },
+    }
+
+    for _, codec := range []Codec{Gob, MsgPack} {
+        var out AppendEntriesArgs
+        codecRoundTrip(t, codec, in, &out)
+        if !reflect.DeepEqual(in, out) {
+            t.Errorf("%v: expected %+v, got %+v", codec.Name(), in, out)
+        }
+    }
+
+    type Unregistered struct{ X int }
+    var buf bytes.Buffer
+    err := NewCodecEncoder(MsgPack, &buf).Encode(LogEntry{1, Unregistered{}})
+    if err == nil || !strings.Contains(err.Error(), "not registered") {
+        t.Fatalf("expected an unregistered type error, got %v", err)
+    }
+}
+
+func TestMsgPackStream(t *testing.T) {
+    var buf bytes.Buffer
+    encoder := NewCodecEncoder(MsgPack, &buf)
+    for i := 0; i < 3; i++ {
+        encoder.Encode(AlphaStruct{IntKey: i, StringKey: "k"})
+    }
+
+    // the decoder buffers, so values must be read through one decoder.
+    decoder := NewCodecDecoder(MsgPack, &buf)
+    for i := 0; i < 3; i++ {
+        var out AlphaStruct
+        if err := decoder.Decode(&out); err != nil {
+            t.Fatalf("decode %d: %v", i, err)
+        }
+        if out.IntKey != i || out.StringKey != "k" {
+            t.Fatalf("decode %d: got %+v", i, out)
+        }
+    }
+}
+
+// how many bytes f allocates.
+func allocated(f func()) uint64 {
+    var before, after runtime.MemStats
+    runtime.ReadMemStats(&before)
+    f()
+    runtime.ReadMemStats(&after)
+    return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestMsgPackHugeLengths(t *testing.T) {
+    // each claims 2^32-1 bytes, elements or entries, then stops.
+    huge := []byte{0xff, 0xff, 0xff, 0xff, 1, 2, 3}
+    cases := []struct {
+        name string
+        code byte
+        into interface{}
+    }{
+        {"bin32", mpBin32, new([]byte)},
+        {"str32", mpStr32, new(string)},
+        {"array32", mpArray32, new([]int)},
+        {"map32", mpMap32, new(map[int]int)},
+    }
+    for _, c := range cases {
+        message := append([]byte{c.code}, huge...)
+        var err error
+        n := allocated(func() {
+            err = NewCodecDecoder(MsgPack, bytes.NewReader(message)).Decode(c.into)
+        })
+        if err == nil {
+            t.Fatalf("%v: expected a truncated message to fail", c.name)
+        }
+        if n > 4<<20 {
+            t.Fatalf("%v: %d bytes allocated for a %d-byte message", c.name, n, len(message))
+        }
+    }
+
+    // a genuinely large one still decodes.
+    big := make([]int, 3*maxPrealloc)
+    for i := range big {
+        big[i] = i
+    }
+    var buf bytes.Buffer
+    NewCodecEncoder(MsgPack, &buf).Encode(big)
+    var out []int
+    if err := NewCodecDecoder(MsgPack, &buf).Decode(&out); err != nil || !reflect.DeepEqual(out, big) {
+        t.Fatalf("expected %d ints back, got %d, %v", len(big), len(out), err)
+    }
+}
+
+func TestMsgPackSkipDepth(t *testing.T) {
+    // a struct with one more field than the receiver's, that field
+    // a million arrays each holding the next.
+    message := []byte{0x92, 1}
+    message = append(message, bytes.Repeat([]byte{0x91}, 1<<20)...)
+    var out struct{ Key int }
+    err := NewCodecDecoder(MsgPack, bytes.NewReader(message)).Decode(&out)
+    if err == nil || !strings.Contains(err.Error(), "nested") {
+        t.Fatalf("expected a nesting error, got %v", err)
+    }
+
+    // shallower nesting is skipped as before.
+    message = append([]byte{0x92, 1}, bytes.Repeat([]byte{0x91}, DefaultMaxDepth-1)...)
+    message = append(message, 0xc0)
+    if err := NewCodecDecoder(MsgPack, bytes.NewReader(message)).Decode(&out); err != nil || out.Key != 1 {
+        t.Fatalf("expected Key 1, got %+v, %v", out, err)
+    }
+}
+
+func TestMsgPackFieldsChange(t *testing.T) {
+    type Old struct {
+        Key   string
+        Value string
+    }
+    type New struct {
+        Key     string
+        Value   string
+        Version int
+    }
+
+    // fields added at the end are left alone by an older sender,
+    // and ignored by an older receiver.
+    var newer New
+    codecRoundTrip(t, MsgPack, Old{"k", "v"}, &newer)
+    if newer != (New{"k", "v", 0}) {
+        t.Fatalf("got %+v", newer)
+    }
+    var older Old
+    codecRoundTrip(t, MsgPack, New{"k", "v", 3}, &older)
+    if older != (Old{"k", "v"}) {
+        t.Fatalf("got %+v", older)
+    }
+
+    var small struct{ Key int8 }
+    var buf bytes.Buffer
+    NewCodecEncoder(MsgPack, &buf).Encode(struct{ Key int }{1000})
+    if err := NewCodecDecoder(MsgPack, &buf).Decode(&small); err == nil {
+        t.Fatalf("expected overflow error")
+    }
+}
+
+func TestCodecChecks(t *testing.T) {
+    for _, codec := range []Codec{JSON, MsgPack} {
+        var buf bytes.Buffer
+        NewCodecEncoder(codec, &buf).Encode(GammaStruct{IntKey: 42})
+        gammaStructDec := GammaStruct{IntKey: 89}
+        decoder := NewCodecDecoder(codec, &buf)
+        decoder.Decode(&gammaStructDec)
+
+        if decoder.ErrorCount() != 1 {
+            t.Errorf("%v: expected %d, got %d", codec.Name(), 1, decoder.ErrorCount())
+        }
+    }
+}
+
+func TestLookupCodec(t *testing.T) {
+    for _, name := range []string{"", "gob", "json", "msgpack"} {
+        if _, ok := LookupCodec(name); !ok {
+            t.Errorf("codec %q not found", name)
+        }
+    }
+    if _, ok := LookupCodec("protobuf"); ok {
+        t.Errorf("unexpected codec protobuf")
+    }
+}
+
+//
+// compare codecs on a Raft-like AppendEntries with a
+// batch of log entries.
+//
+
+func benchArgs() AppendEntriesArgs {
+    Register(AlphaStruct{})
+    args := AppendEntriesArgs{Term: 12, LeaderId: 3, PrevLogIndex: 1000, PrevLogTerm: 11, LeaderCommit: 990}
+    for i := 0; i < 32; i++ {
+        args.Entries = append(args.Entries, LogEntry{12, AlphaStruct{i, i * 7, "key", "some value"}})
+    }
+    return args
+}
+
+func BenchmarkEncode(b *testing.B) {
+    args := benchArgs()
+    for _, codec := range []Codec{Gob, JSON, MsgPack} {
+        b.Run(codec.Name(), func(b *testing.B) {
+            var buf bytes.Buffer
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                // a fresh encoder per message, as labrpc uses them.
+                buf.Reset()
+                NewCodecEncoder(codec, &buf).Encode(args)
+            }
+            b.ReportMetric(float64(buf.Len()), "wire-bytes")
+        })
+    }
+}
+
+func BenchmarkDecode(b *testing.B) {
+    args := benchArgs()
+    for _, codec := range []Codec{Gob, JSON, MsgPack} {
+        b.Run(codec.Name(), func(b *testing.B) {
+            var buf bytes.Buffer
+            NewCodecEncoder(codec, &buf).Encode(args)
+            data := buf.Bytes()
+            b.ReportAllocs()
+            b.ResetTimer()
+            for i := 0; i < b.N; i++ {
+                var out AppendEntriesArgs
+                NewCodecDecoder(codec, bytes.NewReader(data)).Decode(&out)
+            }
+            b.ReportMetric(float64(len(data)), "wire-bytes")
+        })
+    }
+}
@@ -0,0 +1,167 @@
+package labgob
+
+//
+// a versioned envelope for persisted Raft state and snapshots,
+// so that a blob written before a struct changed is either
+// migrated to the current layout or refused, never decoded
+// silently wrong:
+//
+//    var raftSchema = labgob.NewSchema("raft", 2)
+//
+//    func init() {
+//        raftSchema.Migrate(0, migrateV0)
+//        raftSchema.Migrate(1, labgob.Convert(func(old RaftStateV1) RaftState { ... }))
+//    }
+//
+//    data, err := raftSchema.Encode(state)
+//    persister.SaveRaftState(data)
+//    ...
+//    err := raftSchema.Decode(persister.ReadRaftState(), &state)
+//
+// a blob is the 4-byte magic "LGBV", the schema version and a
+// CRC-32 of the payload, both big-endian uint32s, then the
+// gob-encoded payload. a blob without the magic is taken to be
+// version 0, i.e. the raw gob written before envelopes existed.
+//
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "sync"
+)
+
+const envelopeMagic = "LGBV"
+const envelopeHeaderSize = len(envelopeMagic) + 4 + 4
+
+var ErrChecksum = errors.New("labgob: envelope checksum mismatch")
+
+// turns a payload written by one version into the
+// same data as the next version would write it.
+type Migration func(payload []byte) ([]byte, error)
+
+type Schema struct {
+    name       string
+    version    int
+    mu         sync.Mutex
+    migrations map[int]Migration // from version -> to version+1
+}
+
+// a schema whose blobs are currently written as version.
+func NewSchema(name string, version int) *Schema {
+    schema := &Schema{}
+    schema.name = name
+    schema.version = version
+    schema.migrations = map[int]Migration{}
+    return schema
+}
+
+func (schema *Schema) Version() int {
+    return schema.version
+}
+
+// register the migration from version from to from+1.
+func (schema *Schema) Migrate(from int, migration Migration) {
+    if from < 0 || from >= schema.version {
+        panic(fmt.Sprintf("labgob: schema %v: no migration from version %d to the current %d",
+            schema.name, from, schema.version))
+    }
+    schema.mu.Lock()
+    defer schema.mu.Unlock()
+    schema.migrations[from] = migration
+}
+
+// a Migration for a payload holding a single Old value.
+func Convert[Old any, New any](convert func(Old) New) Migration {
+    return func(payload []byte) ([]byte, error) {
+        var old Old
+        if err := NewDecoder(bytes.NewReader(payload)).Decode(&old); err != nil {
+            return nil, err
+        }
+        var buf bytes.Buffer
+        if err := NewEncoder(&buf).Encode(convert(old)); err != nil {
+            return nil, err
+        }
+        return buf.Bytes(), nil
+    }
+}
+
+// wrap payload, written at the current version, in an envelope.
+func (schema *Schema) Seal(payload []byte) []byte {
+    blob := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(payload))
+    copy(blob, envelopeMagic)
+    binary.BigEndian.PutUint32(blob[4:], uint32(schema.version))
+    binary.BigEndian.PutUint32(blob[8:], crc32.ChecksumIEEE(payload))
+    return append(blob, payload...)
+}
+
+// the payload of blob, migrated to the current version. an
+// empty blob, as from a Persister that was never written,
+// has an empty payload.
+func (schema *Schema) Open(blob []byte) ([]byte, error) {
+    if len(blob) == 0 {
+        return nil, nil
+    }
+
+    version, payload, err := openEnvelope(blob)
+    if err != nil {
+        return nil, fmt.Errorf("labgob: schema %v: %w", schema.name, err)
+    }
+    if version > schema.version {
+        return nil, fmt.Errorf("labgob: schema %v: blob was written by version %d, newer than %d",
+            schema.name, version, schema.version)
+    }
+
+    for ; version < schema.version; version++ {
+        schema.mu.Lock()
+        migration, ok := schema.migrations[version]
+        schema.mu.Unlock()
+        if !ok {
+            return nil, fmt.Errorf("labgob: schema %v: no migration from version %d to %d",
+                schema.name, version, version+1)
+        }
+        if payload, err = migration(payload); err != nil {
+            return nil, fmt.Errorf("labgob: schema %v: migrating from version %d to %d: %w",
+                schema.name, version, version+1, err)
+        }
+    }
+    return payload, nil
+}
+
+func openEnvelope(blob []byte) (int, []byte, error) {
+    if len(blob) < len(envelopeMagic) || string(blob[:len(envelopeMagic)]) != envelopeMagic {
+        // written before envelopes.
+        return 0, blob, nil
+    }
+    if len(blob) < envelopeHeaderSize {
+        return 0, nil, fmt.Errorf("envelope header truncated to %d bytes", len(blob))
+    }
+    version := int(binary.BigEndian.Uint32(blob[4:]))
+    sum := binary.BigEndian.Uint32(blob[8:])
+    payload := blob[envelopeHeaderSize:]
+    if crc32.ChecksumIEEE(payload) != sum {
+        return 0, nil, ErrChecksum
+    }
+    return version, payload, nil
+}
+
+// encode a single value at the current version.
+func (schema *Schema) Encode(value interface{}) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := NewEncoder(&buf).Encode(value); err != nil {
+        return nil, err
+    }
+    return schema.Seal(buf.Bytes()), nil
+}
+
+// decode a single value from blob, migrating it from the
+// version that wrote it. an empty blob leaves value alone.
+func (schema *Schema) Decode(blob []byte, value interface{}) error {
+    payload, err := schema.Open(blob)
+    if err != nil || len(payload) == 0 {
+        return err
+    }
+    return NewDecoder(bytes.NewReader(payload)).Decode(value)
+}
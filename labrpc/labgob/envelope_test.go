@@ -0,0 +1,179 @@
+package labgob
+
+import (
+    "bytes"
+    "errors"
+    "flag"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+var updateFixtures = flag.Bool("update", false, "rewrite the fixtures in testdata")
+
+//
+// three versions of a Raft's persistent state. version 0 used
+// -1 for no vote; version 1 numbers peers from 1, so that the
+// default 0 means no vote; version 2 records each entry's index.
+//
+
+type EntryV0 struct {
+    Term    int
+    Command string
+}
+
+type RaftStateV0 struct {
+    CurrentTerm int
+    VotedFor    int
+    Log         []EntryV0
+}
+
+type RaftStateV1 struct {
+    CurrentTerm int
+    VotedFor    int
+    Log         []EntryV0
+}
+
+type EntryV2 struct {
+    Term    int
+    Index   int
+    Command string
+}
+
+type RaftStateV2 struct {
+    CurrentTerm int
+    VotedFor    int
+    Log         []EntryV2
+}
+
+func makeRaftSchema() *Schema {
+    schema := NewSchema("raft", 2)
+    schema.Migrate(0, Convert(func(old RaftStateV0) RaftStateV1 {
+        return RaftStateV1{old.CurrentTerm, old.VotedFor + 1, old.Log}
+    }))
+    schema.Migrate(1, Convert(func(old RaftStateV1) RaftStateV2 {
+        state := RaftStateV2{CurrentTerm: old.CurrentTerm, VotedFor: old.VotedFor}
+        for i, entry := range old.Log {
+            state.Log = append(state.Log, EntryV2{entry.Term, i + 1, entry.Command})
+        }
+        return state
+    }))
+    return schema
+}
+
+// what each fixture holds, once migrated to version 2.
+var fixtureLog = []EntryV0{{1, "put x 1"}, {1, "append x 2"}, {3, "get x"}}
+
+func TestEnvelopeFixtures(t *testing.T) {
+    if *updateFixtures {
+        var buf bytes.Buffer
+        NewEncoder(&buf).Encode(RaftStateV0{3, -1, fixtureLog})
+        os.WriteFile(filepath.Join("testdata", "raftstate-v0.gob"), buf.Bytes(), 0644)
+
+        data, _ := NewSchema("raft", 1).Encode(RaftStateV1{3, 2, fixtureLog})
+        os.WriteFile(filepath.Join("testdata", "raftstate-v1.lgbv"), data, 0644)
+    }
+
+    expectedLog := []EntryV2{{1, 1, "put x 1"}, {1, 2, "append x 2"}, {3, 3, "get x"}}
+    tests := []struct {
+        fixture  string
+        expected RaftStateV2
+    }{
+        {"raftstate-v0.gob", RaftStateV2{3, 0, expectedLog}},
+        {"raftstate-v1.lgbv", RaftStateV2{3, 2, expectedLog}},
+    }
+
+    schema := makeRaftSchema()
+    for _, test := range tests {
+        data, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+        if err != nil {
+            t.Fatalf("%v: %v", test.fixture, err)
+        }
+        var state RaftStateV2
+        if err := schema.Decode(data, &state); err != nil {
+            t.Fatalf("%v: %v", test.fixture, err)
+        }
+        if !reflect.DeepEqual(state, test.expected) {
+            t.Fatalf("%v: expected %+v, got %+v", test.fixture, test.expected, state)
+        }
+    }
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+    schema := makeRaftSchema()
+    in := RaftStateV2{5, 1, []EntryV2{{4, 1, "x"}}}
+    data, err := schema.Encode(in)
+    if err != nil {
+        t.Fatalf("encode: %v", err)
+    }
+    if !bytes.HasPrefix(data, []byte("LGBV")) {
+        t.Fatalf("expected the magic at the start, got %q", data[:4])
+    }
+
+    var out RaftStateV2
+    if err := schema.Decode(data, &out); err != nil {
+        t.Fatalf("decode: %v", err)
+    }
+    if !reflect.DeepEqual(in, out) {
+        t.Fatalf("expected %+v, got %+v", in, out)
+    }
+
+    // nothing persisted yet.
+    out = RaftStateV2{}
+    if err := schema.Decode(nil, &out); err != nil || out.CurrentTerm != 0 {
+        t.Fatalf("expected an empty blob to leave state alone, got %v, %+v", err, out)
+    }
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+    schema := makeRaftSchema()
+    data, _ := schema.Encode(RaftStateV2{CurrentTerm: 1})
+
+    corrupt := append([]byte{}, data...)
+    corrupt[len(corrupt)-1] ^= 0xff
+    var state RaftStateV2
+    if err := schema.Decode(corrupt, &state); !errors.Is(err, ErrChecksum) {
+        t.Fatalf("expected a checksum error, got %v", err)
+    }
+
+    if err := schema.Decode(data[:6], &state); err == nil || !strings.Contains(err.Error(), "truncated") {
+        t.Fatalf("expected a truncation error, got %v", err)
+    }
+
+    newer, _ := NewSchema("raft", 3).Encode(RaftStateV2{})
+    if err := schema.Decode(newer, &state); err == nil || !strings.Contains(err.Error(), "newer") {
+        t.Fatalf("expected a newer version error, got %v", err)
+    }
+
+    // no way from version 0 to version 1.
+    partial := NewSchema("raft", 2)
+    partial.Migrate(1, Convert(func(old RaftStateV1) RaftStateV2 { return RaftStateV2{} }))
+    legacy, _ := os.ReadFile(filepath.Join("testdata", "raftstate-v0.gob"))
+    if err := partial.Decode(legacy, &state); err == nil || !strings.Contains(err.Error(), "no migration from version 0") {
+        t.Fatalf("expected a missing migration error, got %v", err)
+    }
+}
+
+func TestEnvelopeSealOpen(t *testing.T) {
+    // Raft encodes several values into one payload.
+    schema := NewSchema("raft", 1)
+    var buf bytes.Buffer
+    encoder := NewEncoder(&buf)
+    encoder.Encode(7)
+    encoder.Encode("votedFor")
+
+    payload, err := schema.Open(schema.Seal(buf.Bytes()))
+    if err != nil {
+        t.Fatalf("open: %v", err)
+    }
+    decoder := NewDecoder(bytes.NewReader(payload))
+    var term int
+    var what string
+    decoder.Decode(&term)
+    decoder.Decode(&what)
+    if term != 7 || what != "votedFor" {
+        t.Fatalf("expected 7 and votedFor, got %v and %v", term, what)
+    }
+}
@@ -5,109 +5,360 @@ package labgob
 // trying to send non-capitalized fields over RPC produces a range of
 // misbehavior, including both mysterious incorrect computation and
 // outright crashes. so this wrapper around Go's encoding/gob warns
-// about non-capitalized field names.
+// about non-capitalized field names, whichever Codec
+// carries the bytes.
+//
+// Encode also looks for values in interface fields, such as a Raft
+// log entry's Command, whose types were never given to Register();
+// see AuditRegistrations().
+//
+// warnings are easily lost in test output. in strict mode, Encode
+// and Decode instead return an error naming the full path of each
+// problem:
+//
+//    labgob.SetStrict(true) // for encoders and decoders made from now on
+//
+// each encoder and decoder keeps its own count of problems, so that
+// tests running in parallel do not see each other's.
 //
 
 import (
     "encoding/gob"
+    "errors"
     "fmt"
     "io"
     "reflect"
     "sync"
+    "sync/atomic"
     "unicode"
     "unicode/utf8"
 )
 
-var mu sync.Mutex
-var errorCount int // for TestCapital
-var checked map[reflect.Type]bool
+var strictDefault atomic.Bool
+var maxDepthDefault atomic.Int64
+
+// whether encoders and decoders made from now on, and Register(),
+// fail instead of printing warnings.
+func SetStrict(strict bool) {
+    strictDefault.Store(strict)
+}
+
+// whether encoders and decoders made from now on are strict.
+func Strict() bool {
+    return strictDefault.Load()
+}
+
+// how deep encoders and decoders made from now on look for
+// problems in values; 0 means DefaultMaxDepth.
+func SetMaxDepth(depth int) {
+    maxDepthDefault.Store(int64(depth))
+}
+
+type CheckProblem int
+
+const (
+    LowerCaseField        CheckProblem = iota // would not be sent
+    UnregisteredInterface                     // concrete type in an interface field was never registered
+    NonDefaultValue                           // decoding may leave the old value in place
+)
+
+// a problem found by labgob's checks.
+type CheckError struct {
+    Problem CheckProblem
+    Path    string       // e.g. "AppendEntriesArgs.Entries[].command" or "Entries[3].Term"
+    Type    reflect.Type // for UnregisteredInterface, the unregistered type
+}
+
+func (checkError *CheckError) Error() string {
+    switch checkError.Problem {
+    case LowerCaseField:
+        return fmt.Sprintf("labgob: lower-case field %v in RPC or persist/snapshot will break your Raft", checkError.Path)
+    case UnregisteredInterface:
+        return fmt.Sprintf("labgob: type %v in interface field %v is not registered", checkError.Type, checkError.Path)
+    default:
+        return fmt.Sprintf("labgob: decoding into non-default variable/field %v may not work", checkError.Path)
+    }
+}
+
+// the state of one encoder's or decoder's checks.
+type checker struct {
+    strict     bool
+    maxDepth   int
+    mu         sync.Mutex
+    errorCount int                   // problems found so far, for tests
+    reported   map[reflect.Type]bool // types whose field names were already counted
+}
+
+func makeChecker() *checker {
+    return &checker{
+        strict:   strictDefault.Load(),
+        maxDepth: int(maxDepthDefault.Load()),
+        reported: map[reflect.Type]bool{},
+    }
+}
 
 type LabEncoder struct {
-    gob *gob.Encoder
+    enc     Encoder
+    checker *checker
 }
 
 func NewEncoder(w io.Writer) *LabEncoder {
-    enc := &LabEncoder{}
-    enc.gob = gob.NewEncoder(w)
-    return enc
+    return NewCodecEncoder(Gob, w)
 }
 
 func (enc *LabEncoder) Encode(e interface{}) error {
-    checkFieldName(e)
-    return enc.gob.Encode(e)
+    if err := enc.checker.checkEncode(e); err != nil {
+        return err
+    }
+    return enc.enc.Encode(e)
 }
 
 func (enc *LabEncoder) EncodeValue(value reflect.Value) error {
-    checkFieldName(value.Interface())
-    return enc.gob.EncodeValue(value)
+    return enc.Encode(value.Interface())
+}
+
+// turn strict mode on or off for this encoder alone.
+func (enc *LabEncoder) SetStrict(strict bool) {
+    enc.checker.setStrict(strict)
+}
+
+// how deep this encoder looks for unregistered interface types.
+func (enc *LabEncoder) SetMaxDepth(depth int) {
+    enc.checker.setMaxDepth(depth)
+}
+
+// how many problems this encoder has found.
+func (enc *LabEncoder) ErrorCount() int {
+    return enc.checker.count()
 }
 
 type LabDecoder struct {
-    gob *gob.Decoder
+    dec     Decoder
+    checker *checker
 }
 
 func NewDecoder(r io.Reader) *LabDecoder {
-    dec := &LabDecoder{}
-    dec.gob = gob.NewDecoder(r)
-    return dec
+    return NewCodecDecoder(Gob, r)
 }
 
 func (dec *LabDecoder) Decode(e interface{}) error {
-    checkFieldName(e)
-    checkFieldValue(e)
-    return dec.gob.Decode(e)
+    if err := dec.checker.checkDecode(e); err != nil {
+        return err
+    }
+    return dec.dec.Decode(e)
+}
+
+// turn strict mode on or off for this decoder alone.
+func (dec *LabDecoder) SetStrict(strict bool) {
+    dec.checker.setStrict(strict)
+}
+
+// how deep this decoder looks for non-default values.
+func (dec *LabDecoder) SetMaxDepth(depth int) {
+    dec.checker.setMaxDepth(depth)
+}
+
+// how many problems this decoder has found.
+func (dec *LabDecoder) ErrorCount() int {
+    return dec.checker.count()
 }
 
+// panics in strict mode if value has lower-case fields,
+// as gob.Register() does for other misuse.
 func Register(value interface{}) {
-    checkFieldName(value)
+    checkRegister(value)
     gob.Register(value)
+    registerType(typeName(value), value)
 }
 
 func RegisterName(name string, value interface{}) {
-    checkFieldName(value)
+    checkRegister(value)
     gob.RegisterName(name, value)
+    registerType(name, value)
+}
+
+func checkRegister(value interface{}) {
+    if err := makeChecker().checkFieldName(value); err != nil {
+        panic(err)
+    }
+}
+
+func (checker *checker) setStrict(strict bool) {
+    checker.mu.Lock()
+    defer checker.mu.Unlock()
+    checker.strict = strict
+}
+
+func (checker *checker) setMaxDepth(depth int) {
+    checker.mu.Lock()
+    defer checker.mu.Unlock()
+    checker.maxDepth = depth
+}
+
+func (checker *checker) depth() int {
+    checker.mu.Lock()
+    defer checker.mu.Unlock()
+    if checker.maxDepth <= 0 {
+        return DefaultMaxDepth
+    }
+    return checker.maxDepth
+}
+
+func (checker *checker) count() int {
+    checker.mu.Lock()
+    defer checker.mu.Unlock()
+    return checker.errorCount
+}
+
+func (checker *checker) checkEncode(value interface{}) error {
+    if err := checker.checkFieldName(value); err != nil {
+        return err
+    }
+    if value == nil {
+        return nil
+    }
+    // cheap unless the type has interfaces in it.
+    v := reflect.ValueOf(value)
+    walk := makeWalk(v, checker.depth())
+    walk.interfaces(v, walk.maxDepth)
+    for _, problem := range walk.problems {
+        recordMissing(walk.root, problem)
+    }
+    return checker.report(walk.problems)
 }
 
-func checkFieldName(value interface{}) {
-    checkFieldNameInternal(reflect.TypeOf(value))
+func (checker *checker) checkDecode(value interface{}) error {
+    if err := checker.checkFieldName(value); err != nil {
+        return err
+    }
+    return checker.checkFieldValue(value)
 }
 
-func checkFieldNameInternal(t reflect.Type) {
-    mu.Lock()
-    // only complain once, and avoid recursion.
-    if checked == nil {
-        checked = map[reflect.Type]bool{}
+// count problems, and print them unless in strict mode,
+// in which case they are returned as a single error.
+func (checker *checker) report(problems []*CheckError) error {
+    if len(problems) == 0 {
+        return nil
+    }
+
+    checker.mu.Lock()
+    checker.errorCount += len(problems)
+    strict := checker.strict
+    checker.mu.Unlock()
+
+    if !strict {
+        for _, problem := range problems {
+            warn(problem)
+        }
+        return nil
+    }
+    errs := make([]error, len(problems))
+    for i, problem := range problems {
+        errs[i] = problem
     }
-    if checked[t] {
-        mu.Unlock()
+    return errors.Join(errs...)
+}
+
+var warnMu sync.Mutex
+var warned = map[CheckError]bool{}
+
+// only complain once per process about each problem, however
+// many encoders and decoders find it.
+func warn(problem *CheckError) {
+    warnMu.Lock()
+    defer warnMu.Unlock()
+    if warned[*problem] {
         return
     }
-    checked[t] = true
-    mu.Unlock()
+    warned[*problem] = true
+
+    switch problem.Problem {
+    case LowerCaseField:
+        // ta da
+        fmt.Printf("labgob error: lower-case field %v in RPC or persist/snapshot will break your Raft\n",
+            problem.Path)
+    case NonDefaultValue:
+        // this warning typically arises if code re-uses the same RPC reply
+        // variable for multiple RPC calls, or if code restores persisted
+        // state into variable that already have non-default values.
+        fmt.Printf("labgob warning: Decoding into a non-default variable/field %v may not work\n", problem.Path)
+    case UnregisteredInterface:
+        fmt.Printf("labgob warning: type %v in interface field %v is not registered\n", problem.Type, problem.Path)
+    }
+}
+
+// lower-case fields reachable from each type, found once
+// per process since they never change.
+var fieldNameCache sync.Map // reflect.Type -> []*CheckError
+
+func (checker *checker) checkFieldName(value interface{}) error {
+    if value == nil {
+        return nil
+    }
+    t := reflect.TypeOf(value)
+
+    var problems []*CheckError
+    if cached, ok := fieldNameCache.Load(t); ok {
+        problems = cached.([]*CheckError)
+    } else {
+        problems = []*CheckError{}
+        checkFieldNameInternal(t, typeLabel(t), map[reflect.Type]bool{}, &problems)
+        fieldNameCache.Store(t, problems)
+    }
+
+    checker.mu.Lock()
+    // only count a type's problems once, unless they
+    // are about to be returned.
+    if len(problems) == 0 || (checker.reported[t] && !checker.strict) {
+        checker.mu.Unlock()
+        return nil
+    }
+    checker.reported[t] = true
+    checker.mu.Unlock()
+    return checker.report(problems)
+}
+
+func typeLabel(t reflect.Type) string {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    if t.Name() != "" {
+        return t.Name()
+    }
+    return t.String()
+}
+
+func checkFieldNameInternal(t reflect.Type, path string, visiting map[reflect.Type]bool, problems *[]*CheckError) {
+    // avoid recursion.
+    if visiting[t] {
+        return
+    }
+    visiting[t] = true
+    defer delete(visiting, t)
 
     k := t.Kind()
     switch k {
     case reflect.Struct:
         for i := 0; i < t.NumField(); i++ {
             f := t.Field(i)
+            fieldPath := path + "." + f.Name
             rune, _ := utf8.DecodeRuneInString(f.Name)
             if !unicode.IsUpper(rune) {
                 // ta da
-                fmt.Printf("labgob error: lower-case field %v of %v in RPC or persist/snapshot will break your Raft\n",
-                    f.Name, t.Name())
-                mu.Lock()
-                errorCount += 1
-                mu.Unlock()
+                *problems = append(*problems, &CheckError{Problem: LowerCaseField, Path: fieldPath})
             }
-            checkFieldNameInternal(f.Type)
+            checkFieldNameInternal(f.Type, fieldPath, visiting, problems)
         }
         return
-    case reflect.Slice, reflect.Array, reflect.Ptr:
-        checkFieldNameInternal(t.Elem())
+    case reflect.Slice, reflect.Array:
+        checkFieldNameInternal(t.Elem(), path+"[]", visiting, problems)
+        return
+    case reflect.Ptr:
+        checkFieldNameInternal(t.Elem(), path, visiting, problems)
         return
     case reflect.Map:
-        checkFieldNameInternal(t.Elem())
-        checkFieldNameInternal(t.Key())
+        checkFieldNameInternal(t.Elem(), path+"[]", visiting, problems)
+        checkFieldNameInternal(t.Key(), path+"[key]", visiting, problems)
         return
     default:
         return
@@ -119,62 +370,12 @@ func checkFieldNameInternal(t reflect.Type) {
 // struct was already modified. if the RPC reply
 // contains default values, GOB won't overwrite
 // the non-default value with the default value.
-func checkFieldValue(value interface{}) {
+func (checker *checker) checkFieldValue(value interface{}) error {
     if value == nil {
-        return
-    }
-    checkFieldValueInternalWithDepth(reflect.ValueOf(value), 3, "")
-}
-
-func checkFieldValueInternalWithDepth(value reflect.Value, depth int, name string) {
-    if depth == 0 {
-        return
-    }
-
-    if !value.CanInterface() {
-        return
-    }
-
-    t := value.Type()
-    k := t.Kind()
-
-    switch k {
-    case reflect.Struct:
-        for i := 0; i < t.NumField(); i++ {
-            field := value.Field(i)
-            fieldName := t.Field(i).Name
-            if name != "" {
-                fieldName = name + "." + fieldName
-            }
-            checkFieldValueInternalWithDepth(field, depth-1, fieldName)
-        }
-        return
-    case reflect.Ptr:
-        if value.IsNil() {
-            return
-        }
-        checkFieldValueInternalWithDepth(value.Elem(), depth-1, name)
-        return
-    case reflect.Bool,
-        reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-        reflect.Uintptr, reflect.Float32, reflect.Float64,
-        reflect.String:
-        if !reflect.DeepEqual(reflect.Zero(t).Interface(), value.Interface()) {
-            mu.Lock()
-            if errorCount < 1 {
-                what := name
-                if what == "" {
-                    what = t.Name()
-                }
-                // this warning typically arises if code re-uses the same RPC reply
-                // variable for multiple RPC calls, or if code restores persisted
-                // state into variable that already have non-default values.
-                fmt.Printf("labgob warning: Decoding into a non-default variable/field %v may not work\n", what)
-            }
-            errorCount += 1
-            mu.Unlock()
-        }
-        return
+        return nil
     }
+    v := reflect.ValueOf(value)
+    walk := makeWalk(v, checker.depth())
+    walk.nonDefault(v, walk.maxDepth)
+    return checker.report(walk.problems)
 }
@@ -2,6 +2,9 @@ package labgob
 
 import (
     "bytes"
+    "errors"
+    "fmt"
+    "reflect"
     "testing"
 )
 
@@ -53,8 +56,6 @@ type BetaStruct struct {
 }
 
 func TestLabGobCapitalWarning(t *testing.T) {
-    initialErrorCount := errorCount
-
     Register(BetaStruct{})
 
     var buf bytes.Buffer
@@ -67,8 +68,8 @@ func TestLabGobCapitalWarning(t *testing.T) {
     var betaStructDec BetaStruct
     decoder.Decode(&betaStructDec)
 
-    if errorCount != initialErrorCount+1 {
-        t.Errorf("Expected %d, got %d", initialErrorCount+1, errorCount)
+    if encoder.ErrorCount() != 1 {
+        t.Errorf("Expected %d, got %d", 1, encoder.ErrorCount())
     }
 
     if !betaStructDec.Yes {
@@ -85,8 +86,6 @@ type GammaStruct struct {
 }
 
 func TestLabGobNonDefaultWarning(t *testing.T) {
-    initialErrorCount := errorCount
-
     Register(GammaStruct{})
 
     var buf bytes.Buffer
@@ -100,7 +99,114 @@ func TestLabGobNonDefaultWarning(t *testing.T) {
     gammaStructDec.IntKey = 89
     decoder.Decode(&gammaStructDec)
 
-    if errorCount != initialErrorCount+1 {
-        t.Errorf("Expected %d, got %d", initialErrorCount+1, errorCount)
+    if decoder.ErrorCount() != 1 {
+        t.Errorf("Expected %d, got %d", 1, decoder.ErrorCount())
+    }
+}
+
+type StrictArgs struct {
+    Term    int
+    Entries []StrictEntry
+    Leader  *StrictLeader
+}
+
+type StrictEntry struct {
+    Command interface{}
+}
+
+type StrictLeader struct {
+    Id    int
+    index int
+}
+
+type UnregisteredCommand struct {
+    Key string
+}
+
+func TestStrictLowerCase(t *testing.T) {
+    var buf bytes.Buffer
+    encoder := NewEncoder(&buf)
+    encoder.SetStrict(true)
+
+    // every time, not just the first.
+    for i := 0; i < 2; i++ {
+        err := encoder.Encode(StrictArgs{Leader: &StrictLeader{}})
+        var checkError *CheckError
+        if !errors.As(err, &checkError) || checkError.Problem != LowerCaseField {
+            t.Fatalf("expected a lower-case field error, got %v", err)
+        }
+        if checkError.Path != "StrictArgs.Leader.index" {
+            t.Fatalf("expected path StrictArgs.Leader.index, got %v", checkError.Path)
+        }
+    }
+    if buf.Len() != 0 {
+        t.Fatalf("expected nothing to be encoded")
+    }
+    if encoder.ErrorCount() != 2 {
+        t.Fatalf("expected %d, got %d", 2, encoder.ErrorCount())
+    }
+}
+
+func TestStrictUnregisteredInterface(t *testing.T) {
+    var buf bytes.Buffer
+    encoder := NewEncoder(&buf)
+    encoder.SetStrict(true)
+
+    err := encoder.Encode(StrictEntry{Command: UnregisteredCommand{"k"}})
+    var checkError *CheckError
+    if !errors.As(err, &checkError) || checkError.Problem != UnregisteredInterface {
+        t.Fatalf("expected an unregistered interface error, got %v", err)
+    }
+    if checkError.Path != "Command" || checkError.Type != reflect.TypeOf(UnregisteredCommand{}) {
+        t.Fatalf("expected Command of type UnregisteredCommand, got %v of %v", checkError.Path, checkError.Type)
+    }
+
+    // basic types need no registration.
+    if err := encoder.Encode(StrictEntry{Command: 42}); err != nil {
+        t.Fatalf("expected int in an interface to encode, got %v", err)
+    }
+}
+
+func TestStrictNonDefault(t *testing.T) {
+    var buf bytes.Buffer
+    NewEncoder(&buf).Encode(GammaStruct{IntKey: 42})
+
+    decoder := NewDecoder(&buf)
+    decoder.SetStrict(true)
+    gammaStructDec := GammaStruct{IntKey: 89}
+    err := decoder.Decode(&gammaStructDec)
+    var checkError *CheckError
+    if !errors.As(err, &checkError) || checkError.Problem != NonDefaultValue || checkError.Path != "IntKey" {
+        t.Fatalf("expected a non-default IntKey error, got %v", err)
+    }
+    if gammaStructDec.IntKey != 89 {
+        t.Fatalf("expected the value to be left alone, got %d", gammaStructDec.IntKey)
+    }
+}
+
+func TestStrictDefault(t *testing.T) {
+    SetStrict(true)
+    encoder := NewEncoder(new(bytes.Buffer))
+    SetStrict(false)
+
+    if err := encoder.Encode(BetaStruct{}); err == nil {
+        t.Fatalf("expected encoders made in strict mode to be strict")
+    }
+    if err := NewEncoder(new(bytes.Buffer)).Encode(BetaStruct{}); err != nil {
+        t.Fatalf("expected a warning only, got %v", err)
+    }
+}
+
+func TestErrorCountPerEncoder(t *testing.T) {
+    for i := 0; i < 4; i++ {
+        t.Run(fmt.Sprint(i), func(t *testing.T) {
+            t.Parallel()
+            encoder := NewEncoder(new(bytes.Buffer))
+            encoder.Encode(BetaStruct{})
+            encoder.Encode(BetaStruct{})
+            if encoder.ErrorCount() != 1 {
+                t.Errorf("expected %d, got %d", 1, encoder.ErrorCount())
+            }
+        })
     }
 }
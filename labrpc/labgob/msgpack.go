@@ -0,0 +1,720 @@
+package labgob
+
+//
+// a compact binary codec in the MessagePack format
+// (https://msgpack.org/), written with reflect so that it
+// needs no code generation. a struct is an array of its
+// exported fields in declaration order, so, as with a
+// protobuf schema, both sides must agree on that order;
+// fields may only be added at the end. a value in an
+// interface field is a two-element array of its type and
+// the value: the registered name (see Register()) the first
+// time the type appears in a message, and after that the
+// position of the name among those already sent.
+//
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "math"
+    "reflect"
+    "sync"
+)
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) NewEncoder(w io.Writer) Encoder {
+    return &msgpackEncoder{w: w}
+}
+
+func (msgpackCodec) NewDecoder(r io.Reader) Decoder {
+    br, ok := r.(byteReader)
+    if !ok {
+        br = bufio.NewReader(r)
+    }
+    return &msgpackDecoder{r: br}
+}
+
+const (
+    mpNil     = 0xc0
+    mpFalse   = 0xc2
+    mpTrue    = 0xc3
+    mpBin8    = 0xc4
+    mpBin16   = 0xc5
+    mpBin32   = 0xc6
+    mpFloat32 = 0xca
+    mpFloat64 = 0xcb
+    mpUint8   = 0xcc
+    mpUint16  = 0xcd
+    mpUint32  = 0xce
+    mpUint64  = 0xcf
+    mpInt8    = 0xd0
+    mpInt16   = 0xd1
+    mpInt32   = 0xd2
+    mpInt64   = 0xd3
+    mpStr8    = 0xd9
+    mpStr16   = 0xda
+    mpStr32   = 0xdb
+    mpArray16 = 0xdc
+    mpArray32 = 0xdd
+    mpMap16   = 0xde
+    mpMap32   = 0xdf
+)
+
+type msgpackEncoder struct {
+    w     io.Writer
+    buf   []byte
+    names map[reflect.Type]int // interface types already sent in this message
+}
+
+// each value goes out in a single Write.
+func (enc *msgpackEncoder) Encode(e interface{}) error {
+    enc.buf = enc.buf[:0]
+    enc.names = nil
+    if err := enc.encode(reflect.ValueOf(e)); err != nil {
+        return err
+    }
+    _, err := enc.w.Write(enc.buf)
+    return err
+}
+
+func (enc *msgpackEncoder) encode(value reflect.Value) error {
+    if !value.IsValid() {
+        enc.buf = append(enc.buf, mpNil)
+        return nil
+    }
+
+    switch value.Kind() {
+    case reflect.Bool:
+        if value.Bool() {
+            enc.buf = append(enc.buf, mpTrue)
+        } else {
+            enc.buf = append(enc.buf, mpFalse)
+        }
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        enc.appendInt(value.Int())
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        enc.appendUint(value.Uint())
+    case reflect.Float32:
+        enc.buf = append(enc.buf, mpFloat32)
+        enc.buf = binary.BigEndian.AppendUint32(enc.buf, math.Float32bits(float32(value.Float())))
+    case reflect.Float64:
+        enc.buf = append(enc.buf, mpFloat64)
+        enc.buf = binary.BigEndian.AppendUint64(enc.buf, math.Float64bits(value.Float()))
+    case reflect.String:
+        enc.appendString(value.String())
+    case reflect.Slice:
+        if value.IsNil() {
+            enc.buf = append(enc.buf, mpNil)
+            return nil
+        }
+        if value.Type().Elem().Kind() == reflect.Uint8 {
+            enc.appendBytes(value.Bytes())
+            return nil
+        }
+        return enc.encodeArray(value)
+    case reflect.Array:
+        return enc.encodeArray(value)
+    case reflect.Map:
+        if value.IsNil() {
+            enc.buf = append(enc.buf, mpNil)
+            return nil
+        }
+        enc.appendHeader(value.Len(), 0x80, 0x0f, mpMap16, mpMap32)
+        iter := value.MapRange()
+        for iter.Next() {
+            if err := enc.encode(iter.Key()); err != nil {
+                return err
+            }
+            if err := enc.encode(iter.Value()); err != nil {
+                return err
+            }
+        }
+    case reflect.Struct:
+        fields := exportedFields(value.Type())
+        enc.appendHeader(len(fields), 0x90, 0x0f, mpArray16, mpArray32)
+        for _, i := range fields {
+            if err := enc.encode(value.Field(i)); err != nil {
+                return err
+            }
+        }
+    case reflect.Ptr:
+        if value.IsNil() {
+            enc.buf = append(enc.buf, mpNil)
+            return nil
+        }
+        return enc.encode(value.Elem())
+    case reflect.Interface:
+        if value.IsNil() {
+            enc.buf = append(enc.buf, mpNil)
+            return nil
+        }
+        elem := value.Elem()
+        enc.appendHeader(2, 0x90, 0x0f, mpArray16, mpArray32)
+        if index, ok := enc.names[elem.Type()]; ok {
+            enc.appendInt(int64(index))
+        } else {
+            name, ok := registeredName(elem.Type())
+            if !ok {
+                return fmt.Errorf("msgpack: type not registered for interface: %v", elem.Type())
+            }
+            if enc.names == nil {
+                enc.names = map[reflect.Type]int{}
+            }
+            enc.names[elem.Type()] = len(enc.names)
+            enc.appendString(name)
+        }
+        return enc.encode(elem)
+    default:
+        return fmt.Errorf("msgpack: can't encode type %v", value.Type())
+    }
+    return nil
+}
+
+func (enc *msgpackEncoder) encodeArray(value reflect.Value) error {
+    if value.Type().Elem().Kind() == reflect.Uint8 && value.Kind() == reflect.Array {
+        bytes := make([]byte, value.Len())
+        reflect.Copy(reflect.ValueOf(bytes), value)
+        enc.appendBytes(bytes)
+        return nil
+    }
+    enc.appendHeader(value.Len(), 0x90, 0x0f, mpArray16, mpArray32)
+    for i := 0; i < value.Len(); i++ {
+        if err := enc.encode(value.Index(i)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+var fieldsCache sync.Map // reflect.Type -> []int
+
+// indices of t's exported fields.
+func exportedFields(t reflect.Type) []int {
+    if fields, ok := fieldsCache.Load(t); ok {
+        return fields.([]int)
+    }
+    fields := []int{}
+    for i := 0; i < t.NumField(); i++ {
+        if t.Field(i).IsExported() {
+            fields = append(fields, i)
+        }
+    }
+    fieldsCache.Store(t, fields)
+    return fields
+}
+
+func (enc *msgpackEncoder) appendInt(i int64) {
+    switch {
+    case i >= 0:
+        enc.appendUint(uint64(i))
+    case i >= -32:
+        enc.buf = append(enc.buf, byte(i))
+    case i >= math.MinInt8:
+        enc.buf = append(enc.buf, mpInt8, byte(i))
+    case i >= math.MinInt16:
+        enc.buf = append(enc.buf, mpInt16)
+        enc.buf = binary.BigEndian.AppendUint16(enc.buf, uint16(i))
+    case i >= math.MinInt32:
+        enc.buf = append(enc.buf, mpInt32)
+        enc.buf = binary.BigEndian.AppendUint32(enc.buf, uint32(i))
+    default:
+        enc.buf = append(enc.buf, mpInt64)
+        enc.buf = binary.BigEndian.AppendUint64(enc.buf, uint64(i))
+    }
+}
+
+func (enc *msgpackEncoder) appendUint(u uint64) {
+    switch {
+    case u <= 0x7f:
+        enc.buf = append(enc.buf, byte(u))
+    case u <= math.MaxUint8:
+        enc.buf = append(enc.buf, mpUint8, byte(u))
+    case u <= math.MaxUint16:
+        enc.buf = append(enc.buf, mpUint16)
+        enc.buf = binary.BigEndian.AppendUint16(enc.buf, uint16(u))
+    case u <= math.MaxUint32:
+        enc.buf = append(enc.buf, mpUint32)
+        enc.buf = binary.BigEndian.AppendUint32(enc.buf, uint32(u))
+    default:
+        enc.buf = append(enc.buf, mpUint64)
+        enc.buf = binary.BigEndian.AppendUint64(enc.buf, u)
+    }
+}
+
+func (enc *msgpackEncoder) appendString(s string) {
+    switch n := len(s); {
+    case n <= 31:
+        enc.buf = append(enc.buf, 0xa0|byte(n))
+    case n <= math.MaxUint8:
+        enc.buf = append(enc.buf, mpStr8, byte(n))
+    default:
+        enc.appendLength(n, mpStr16, mpStr32)
+    }
+    enc.buf = append(enc.buf, s...)
+}
+
+func (enc *msgpackEncoder) appendBytes(b []byte) {
+    if n := len(b); n <= math.MaxUint8 {
+        enc.buf = append(enc.buf, mpBin8, byte(n))
+    } else {
+        enc.appendLength(n, mpBin16, mpBin32)
+    }
+    enc.buf = append(enc.buf, b...)
+}
+
+// an array or map header: fix|n if n fits in mask, else
+// a 16- or 32-bit length.
+func (enc *msgpackEncoder) appendHeader(n int, fix byte, mask int, code16 byte, code32 byte) {
+    if n <= mask {
+        enc.buf = append(enc.buf, fix|byte(n))
+    } else {
+        enc.appendLength(n, code16, code32)
+    }
+}
+
+func (enc *msgpackEncoder) appendLength(n int, code16 byte, code32 byte) {
+    if n <= math.MaxUint16 {
+        enc.buf = append(enc.buf, code16)
+        enc.buf = binary.BigEndian.AppendUint16(enc.buf, uint16(n))
+    } else {
+        enc.buf = append(enc.buf, code32)
+        enc.buf = binary.BigEndian.AppendUint32(enc.buf, uint32(n))
+    }
+}
+
+type byteReader interface {
+    io.Reader
+    io.ByteReader
+}
+
+type msgpackDecoder struct {
+    r     byteReader
+    names []reflect.Type // interface types seen so far in this message
+}
+
+func (dec *msgpackDecoder) Decode(e interface{}) error {
+    value := reflect.ValueOf(e)
+    if value.Kind() != reflect.Ptr || value.IsNil() {
+        return fmt.Errorf("msgpack: Decode needs a non-nil pointer, not %T", e)
+    }
+    code, err := dec.r.ReadByte()
+    if err != nil {
+        return err
+    }
+    dec.names = dec.names[:0]
+    return dec.decode(code, value.Elem())
+}
+
+// lengths come from the wire, so nothing larger than this is
+// allocated before the data to fill it has arrived; a message
+// claiming 4GB of bytes or elements must actually send them.
+const maxPrealloc = 64 << 10
+
+func (dec *msgpackDecoder) next(n int) ([]byte, error) {
+    if n > maxPrealloc {
+        // grows as the bytes arrive.
+        var buf bytes.Buffer
+        if _, err := io.CopyN(&buf, dec.r, int64(n)); err != nil {
+            return nil, unexpected(err)
+        }
+        return buf.Bytes(), nil
+    }
+    b := make([]byte, n)
+    if _, err := io.ReadFull(dec.r, b); err != nil {
+        return nil, unexpected(err)
+    }
+    return b, nil
+}
+
+// room for at most maxPrealloc elements to begin with.
+func preallocate(n int) int {
+    if n > maxPrealloc {
+        return maxPrealloc
+    }
+    return n
+}
+
+func unexpected(err error) error {
+    if err == io.EOF {
+        return io.ErrUnexpectedEOF
+    }
+    return err
+}
+
+// decode the value whose first byte is code into value.
+func (dec *msgpackDecoder) decode(code byte, value reflect.Value) error {
+    if code == mpNil {
+        value.Set(reflect.Zero(value.Type()))
+        return nil
+    }
+
+    switch value.Kind() {
+    case reflect.Bool:
+        if code != mpTrue && code != mpFalse {
+            return mismatch(code, value)
+        }
+        value.SetBool(code == mpTrue)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        i, u, negative, err := dec.readInteger(code)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        if !negative {
+            if u > math.MaxInt64 {
+                return overflow(u, value)
+            }
+            i = int64(u)
+        }
+        if value.OverflowInt(i) {
+            return overflow(i, value)
+        }
+        value.SetInt(i)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        i, u, negative, err := dec.readInteger(code)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        if negative || value.OverflowUint(u) {
+            return overflow(i, value)
+        }
+        value.SetUint(u)
+    case reflect.Float32, reflect.Float64:
+        f, err := dec.readFloat(code)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        value.SetFloat(f)
+    case reflect.String:
+        b, err := dec.readBytes(code)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        value.SetString(string(b))
+    case reflect.Slice:
+        if value.Type().Elem().Kind() == reflect.Uint8 {
+            if b, err := dec.readBytes(code); err == nil {
+                value.SetBytes(b)
+                return nil
+            } else if err != errMismatch {
+                return err
+            }
+        }
+        n, err := dec.readHeader(code, 0x90, mpArray16, mpArray32)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        if n <= maxPrealloc {
+            value.Set(reflect.MakeSlice(value.Type(), n, n))
+            return dec.decodeElems(n, value)
+        }
+        slice := reflect.MakeSlice(value.Type(), 0, maxPrealloc)
+        zero := reflect.Zero(value.Type().Elem())
+        for i := 0; i < n; i++ {
+            slice = reflect.Append(slice, zero)
+            if err := dec.decodeNext(slice.Index(i)); err != nil {
+                return err
+            }
+        }
+        value.Set(slice)
+        return nil
+    case reflect.Array:
+        if value.Type().Elem().Kind() == reflect.Uint8 {
+            if b, err := dec.readBytes(code); err == nil {
+                if len(b) > value.Len() {
+                    return fmt.Errorf("msgpack: %d bytes do not fit in %v", len(b), value.Type())
+                }
+                reflect.Copy(value, reflect.ValueOf(b))
+                return nil
+            } else if err != errMismatch {
+                return err
+            }
+        }
+        n, err := dec.readHeader(code, 0x90, mpArray16, mpArray32)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        if n > value.Len() {
+            return fmt.Errorf("msgpack: %d elements do not fit in %v", n, value.Type())
+        }
+        return dec.decodeElems(n, value)
+    case reflect.Map:
+        n, err := dec.readHeader(code, 0x80, mpMap16, mpMap32)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        if value.IsNil() {
+            value.Set(reflect.MakeMapWithSize(value.Type(), preallocate(n)))
+        }
+        t := value.Type()
+        for i := 0; i < n; i++ {
+            k := reflect.New(t.Key()).Elem()
+            if err := dec.decodeNext(k); err != nil {
+                return err
+            }
+            v := reflect.New(t.Elem()).Elem()
+            if err := dec.decodeNext(v); err != nil {
+                return err
+            }
+            value.SetMapIndex(k, v)
+        }
+    case reflect.Struct:
+        n, err := dec.readHeader(code, 0x90, mpArray16, mpArray32)
+        if err != nil {
+            return mismatchOr(err, code, value)
+        }
+        fields := exportedFields(value.Type())
+        for i := 0; i < n; i++ {
+            if i >= len(fields) {
+                // as with gob, fields the receiver lacks are ignored.
+                if err := dec.skip(DefaultMaxDepth); err != nil {
+                    return err
+                }
+                continue
+            }
+            if err := dec.decodeNext(value.Field(fields[i])); err != nil {
+                return err
+            }
+        }
+    case reflect.Ptr:
+        if value.IsNil() {
+            value.Set(reflect.New(value.Type().Elem()))
+        }
+        return dec.decode(code, value.Elem())
+    case reflect.Interface:
+        n, err := dec.readHeader(code, 0x90, mpArray16, mpArray32)
+        if err != nil || n != 2 {
+            return mismatch(code, value)
+        }
+        t, err := dec.readInterfaceType()
+        if err != nil {
+            return err
+        }
+        if !t.Implements(value.Type()) {
+            return fmt.Errorf("msgpack: %v does not implement %v", t, value.Type())
+        }
+        elem := reflect.New(t).Elem()
+        if err := dec.decodeNext(elem); err != nil {
+            return err
+        }
+        value.Set(elem)
+    default:
+        return fmt.Errorf("msgpack: can't decode into type %v", value.Type())
+    }
+    return nil
+}
+
+// a registered name, or the position of one read earlier.
+func (dec *msgpackDecoder) readInterfaceType() (reflect.Type, error) {
+    code, err := dec.r.ReadByte()
+    if err != nil {
+        return nil, unexpected(err)
+    }
+    if _, index, negative, err := dec.readInteger(code); err != errMismatch {
+        if err != nil {
+            return nil, err
+        }
+        if negative || index >= uint64(len(dec.names)) {
+            return nil, fmt.Errorf("msgpack: bad interface type index %d", index)
+        }
+        return dec.names[index], nil
+    }
+
+    name, err := dec.readBytes(code)
+    if err != nil {
+        return nil, mismatchOr(err, code, reflect.ValueOf(""))
+    }
+    t, ok := registeredType(string(name))
+    if !ok {
+        return nil, fmt.Errorf("msgpack: name not registered for interface: %q", name)
+    }
+    dec.names = append(dec.names, t)
+    return t, nil
+}
+
+func (dec *msgpackDecoder) decodeNext(value reflect.Value) error {
+    code, err := dec.r.ReadByte()
+    if err != nil {
+        return unexpected(err)
+    }
+    return dec.decode(code, value)
+}
+
+func (dec *msgpackDecoder) decodeElems(n int, value reflect.Value) error {
+    for i := 0; i < n; i++ {
+        if err := dec.decodeNext(value.Index(i)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+var errMismatch = fmt.Errorf("msgpack: type mismatch")
+
+func mismatch(code byte, value reflect.Value) error {
+    return fmt.Errorf("msgpack: can't decode value with code 0x%02x into %v", code, value.Type())
+}
+
+func mismatchOr(err error, code byte, value reflect.Value) error {
+    if err == errMismatch {
+        return mismatch(code, value)
+    }
+    return err
+}
+
+func overflow(x interface{}, value reflect.Value) error {
+    return fmt.Errorf("msgpack: %v overflows %v", x, value.Type())
+}
+
+// an integer as int64 if negative, else as uint64.
+func (dec *msgpackDecoder) readInteger(code byte) (int64, uint64, bool, error) {
+    switch {
+    case code <= 0x7f:
+        return 0, uint64(code), false, nil
+    case code >= 0xe0:
+        return int64(int8(code)), 0, true, nil
+    case code >= mpUint8 && code <= mpUint64:
+        b, err := dec.next(1 << (code - mpUint8))
+        if err != nil {
+            return 0, 0, false, err
+        }
+        return 0, bigEndian(b), false, nil
+    case code >= mpInt8 && code <= mpInt64:
+        b, err := dec.next(1 << (code - mpInt8))
+        if err != nil {
+            return 0, 0, false, err
+        }
+        // sign-extend.
+        shift := 64 - 8*uint(len(b))
+        i := int64(bigEndian(b)<<shift) >> shift
+        return i, uint64(i), i < 0, nil
+    }
+    return 0, 0, false, errMismatch
+}
+
+func bigEndian(b []byte) uint64 {
+    var u uint64
+    for _, x := range b {
+        u = u<<8 | uint64(x)
+    }
+    return u
+}
+
+func (dec *msgpackDecoder) readFloat(code byte) (float64, error) {
+    switch code {
+    case mpFloat32:
+        b, err := dec.next(4)
+        if err != nil {
+            return 0, err
+        }
+        return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+    case mpFloat64:
+        b, err := dec.next(8)
+        if err != nil {
+            return 0, err
+        }
+        return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+    }
+    i, u, negative, err := dec.readInteger(code)
+    if err != nil {
+        return 0, err
+    }
+    if negative {
+        return float64(i), nil
+    }
+    return float64(u), nil
+}
+
+// the contents of a str or bin.
+func (dec *msgpackDecoder) readBytes(code byte) ([]byte, error) {
+    var n int
+    switch {
+    case code&0xe0 == 0xa0:
+        n = int(code & 0x1f)
+    case code == mpStr8 || code == mpBin8:
+        b, err := dec.next(1)
+        if err != nil {
+            return nil, err
+        }
+        n = int(b[0])
+    case code == mpStr16 || code == mpBin16:
+        b, err := dec.next(2)
+        if err != nil {
+            return nil, err
+        }
+        n = int(binary.BigEndian.Uint16(b))
+    case code == mpStr32 || code == mpBin32:
+        b, err := dec.next(4)
+        if err != nil {
+            return nil, err
+        }
+        n = int(binary.BigEndian.Uint32(b))
+    default:
+        return nil, errMismatch
+    }
+    return dec.next(n)
+}
+
+// the length of an array (fix 0x90) or map (fix 0x80).
+func (dec *msgpackDecoder) readHeader(code byte, fix byte, code16 byte, code32 byte) (int, error) {
+    switch {
+    case code&0xf0 == fix:
+        return int(code & 0x0f), nil
+    case code == code16:
+        b, err := dec.next(2)
+        if err != nil {
+            return 0, err
+        }
+        return int(binary.BigEndian.Uint16(b)), nil
+    case code == code32:
+        b, err := dec.next(4)
+        if err != nil {
+            return 0, err
+        }
+        return int(binary.BigEndian.Uint32(b)), nil
+    }
+    return 0, errMismatch
+}
+
+// read past the next value, whatever it is, failing if
+// arrays and maps are nested more than depth deep.
+func (dec *msgpackDecoder) skip(depth int) error {
+    if depth <= 0 {
+        return fmt.Errorf("msgpack: value nested more than %d deep", DefaultMaxDepth)
+    }
+    code, err := dec.r.ReadByte()
+    if err != nil {
+        return unexpected(err)
+    }
+
+    if code == mpNil || code == mpTrue || code == mpFalse {
+        return nil
+    }
+    if _, _, _, err := dec.readInteger(code); err != errMismatch {
+        return err
+    }
+    if _, err := dec.readFloat(code); err != errMismatch {
+        return err
+    }
+    if _, err := dec.readBytes(code); err != errMismatch {
+        return err
+    }
+    if n, err := dec.readHeader(code, 0x90, mpArray16, mpArray32); err != errMismatch {
+        for i := 0; err == nil && i < n; i++ {
+            err = dec.skip(depth - 1)
+        }
+        return err
+    }
+    if n, err := dec.readHeader(code, 0x80, mpMap16, mpMap32); err != errMismatch {
+        for i := 0; err == nil && i < 2*n; i++ {
+            err = dec.skip(depth - 1)
+        }
+        return err
+    }
+    return fmt.Errorf("msgpack: unsupported code 0x%02x", code)
+}
@@ -0,0 +1,372 @@
+package labgob
+
+//
+// streaming for values with large []byte fields, such as the Data
+// of an InstallSnapshot RPC. EncodeStream encodes such a value
+// with the large fields left out, and then writes each large field
+// as it stands, in chunks, so that multi-megabyte snapshots are
+// neither copied into the codec's buffers nor re-encoded by it:
+//
+//    magic "\x00LGS"
+//    uvarint length of the encoded rest, and the rest
+//    uvarint number of large fields, then for each:
+//        uvarint field index, uvarint total length,
+//        chunks written by a ChunkWriter
+//
+// a value without large fields is written exactly as the codec
+// alone would write it, and DecodeStream accepts either form.
+// only the fields of a struct, or of the struct a pointer points
+// to, are considered. each stream holds a single value, and
+// DecodeStream may read past its end unless r is an io.ByteReader.
+//
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "sync"
+)
+
+// []byte fields at least this long are streamed.
+const StreamThreshold = 64 << 10
+
+// the largest chunk a ChunkWriter writes.
+const ChunkSize = 64 << 10
+
+const streamMagic = "\x00LGS"
+
+var bufferPool = sync.Pool{
+    New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// an empty buffer from a pool, to be given back with PutBuffer()
+// once nothing refers to its contents.
+func GetBuffer() *bytes.Buffer {
+    return bufferPool.Get().(*bytes.Buffer)
+}
+
+func PutBuffer(buf *bytes.Buffer) {
+    // don't let one huge message pin its memory forever.
+    if buf.Cap() > 4*ChunkSize {
+        return
+    }
+    buf.Reset()
+    bufferPool.Put(buf)
+}
+
+// the contents of a buffer from GetBuffer(), which is given back.
+// small contents are copied, so the buffer can be re-used; large
+// ones are not, and the buffer is left to the garbage collector.
+func TakeBytes(buf *bytes.Buffer) []byte {
+    if buf.Cap() > 4*ChunkSize {
+        return buf.Bytes()
+    }
+    b := make([]byte, buf.Len())
+    copy(b, buf.Bytes())
+    PutBuffer(buf)
+    return b
+}
+
+// writes a byte stream as chunks, each a uvarint length and
+// that many bytes, ended by Close() with an empty chunk.
+type ChunkWriter struct {
+    w      io.Writer
+    header [binary.MaxVarintLen64]byte
+}
+
+func NewChunkWriter(w io.Writer) *ChunkWriter {
+    return &ChunkWriter{w: w}
+}
+
+func (cw *ChunkWriter) Write(p []byte) (int, error) {
+    written := 0
+    for len(p) > 0 {
+        n := len(p)
+        if n > ChunkSize {
+            n = ChunkSize
+        }
+        if err := cw.writeHeader(n); err != nil {
+            return written, err
+        }
+        m, err := cw.w.Write(p[:n])
+        written += m
+        if err != nil {
+            return written, err
+        }
+        p = p[n:]
+    }
+    return written, nil
+}
+
+func (cw *ChunkWriter) writeHeader(n int) error {
+    h := binary.PutUvarint(cw.header[:], uint64(n))
+    _, err := cw.w.Write(cw.header[:h])
+    return err
+}
+
+// write the empty chunk that ends the stream.
+func (cw *ChunkWriter) Close() error {
+    return cw.writeHeader(0)
+}
+
+// reads what a ChunkWriter wrote, returning io.EOF at
+// the empty chunk.
+type ChunkReader struct {
+    r         byteReader
+    remaining int
+    done      bool
+}
+
+func NewChunkReader(r io.Reader) *ChunkReader {
+    return &ChunkReader{r: asByteReader(r)}
+}
+
+func asByteReader(r io.Reader) byteReader {
+    if br, ok := r.(byteReader); ok {
+        return br
+    }
+    return bufio.NewReader(r)
+}
+
+func (cr *ChunkReader) Read(p []byte) (int, error) {
+    if cr.remaining == 0 {
+        if cr.done {
+            return 0, io.EOF
+        }
+        n, err := binary.ReadUvarint(cr.r)
+        if err != nil {
+            return 0, unexpected(err)
+        }
+        if n == 0 {
+            cr.done = true
+            return 0, io.EOF
+        }
+        if n > ChunkSize {
+            return 0, fmt.Errorf("labgob: chunk of %d bytes is larger than %d", n, ChunkSize)
+        }
+        cr.remaining = int(n)
+    }
+    if len(p) > cr.remaining {
+        p = p[:cr.remaining]
+    }
+    n, err := cr.r.Read(p)
+    cr.remaining -= n
+    if err == io.EOF {
+        err = io.ErrUnexpectedEOF
+    }
+    return n, err
+}
+
+// read total bytes of a streamed field. total comes from the
+// stream, so unless r is known to hold that much, as a
+// bytes.Reader is, memory is only taken as chunks arrive.
+func readField(r byteReader, chunkReader *ChunkReader, total uint64) ([]byte, error) {
+    if remaining, ok := r.(interface{ Len() int }); ok {
+        if total > uint64(remaining.Len()) {
+            return nil, io.ErrUnexpectedEOF
+        }
+        data := make([]byte, total)
+        if _, err := io.ReadFull(chunkReader, data); err != nil {
+            return nil, unexpected(err)
+        }
+        return data, nil
+    }
+
+    if int64(total) < 0 {
+        return nil, fmt.Errorf("labgob: streamed field of %d bytes is too long", total)
+    }
+    var buf bytes.Buffer
+    if total < maxPrealloc {
+        buf.Grow(int(total))
+    } else {
+        buf.Grow(maxPrealloc)
+    }
+    if _, err := io.CopyN(&buf, chunkReader, int64(total)); err != nil {
+        return nil, unexpected(err)
+    }
+    return buf.Bytes(), nil
+}
+
+// the struct e is or points to, if any.
+func streamTarget(value reflect.Value) (reflect.Value, bool) {
+    for value.Kind() == reflect.Ptr {
+        if value.IsNil() {
+            return value, false
+        }
+        value = value.Elem()
+    }
+    return value, value.Kind() == reflect.Struct
+}
+
+func isBytes(t reflect.Type) bool {
+    return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// a codec, and whether its checks are strict, for
+// streams whose strictness is not the process-wide one.
+type Stream struct {
+    Codec  Codec
+    Strict bool
+}
+
+func (stream Stream) encoder(w io.Writer) *LabEncoder {
+    enc := NewCodecEncoder(stream.Codec, w)
+    enc.SetStrict(stream.Strict)
+    return enc
+}
+
+func (stream Stream) decoder(r io.Reader) *LabDecoder {
+    dec := NewCodecDecoder(stream.Codec, r)
+    dec.SetStrict(stream.Strict)
+    return dec
+}
+
+// encode e to w with codec, streaming its large []byte fields.
+func EncodeStream(codec Codec, w io.Writer, e interface{}) error {
+    return Stream{codec, Strict()}.Encode(w, e)
+}
+
+// decode into e, with codec, what EncodeStream wrote to r.
+func DecodeStream(codec Codec, r io.Reader, e interface{}) error {
+    return Stream{codec, Strict()}.Decode(r, e)
+}
+
+func (stream Stream) Encode(w io.Writer, e interface{}) error {
+    s, ok := streamTarget(reflect.ValueOf(e))
+    large := []int{}
+    if ok {
+        for _, f := range infoOf(s.Type()).fields {
+            field := s.Field(f.index)
+            if isBytes(field.Type()) && field.Len() >= StreamThreshold {
+                large = append(large, f.index)
+            }
+        }
+    }
+    if len(large) == 0 {
+        return stream.encoder(w).Encode(e)
+    }
+
+    // a copy without the large fields.
+    rest := reflect.New(s.Type()).Elem()
+    rest.Set(s)
+    for _, i := range large {
+        rest.Field(i).Set(reflect.Zero(rest.Field(i).Type()))
+    }
+    buf := GetBuffer()
+    defer PutBuffer(buf)
+    if err := stream.encoder(buf).Encode(rest.Interface()); err != nil {
+        return err
+    }
+
+    if out, ok := w.(*bytes.Buffer); ok {
+        // grow once, rather than by doubling through the snapshot.
+        size := len(streamMagic) + buf.Len() + 3*binary.MaxVarintLen64
+        for _, i := range large {
+            n := s.Field(i).Len()
+            size += 2*binary.MaxVarintLen64 + n + (n/ChunkSize+2)*binary.MaxVarintLen32
+        }
+        out.Grow(size)
+    }
+
+    header := make([]byte, 0, len(streamMagic)+2*binary.MaxVarintLen64)
+    header = append(header, streamMagic...)
+    header = binary.AppendUvarint(header, uint64(buf.Len()))
+    if _, err := w.Write(header); err != nil {
+        return err
+    }
+    if _, err := w.Write(buf.Bytes()); err != nil {
+        return err
+    }
+
+    header = binary.AppendUvarint(header[:0], uint64(len(large)))
+    if _, err := w.Write(header); err != nil {
+        return err
+    }
+    chunkWriter := NewChunkWriter(w)
+    for _, i := range large {
+        data := s.Field(i).Bytes()
+        header = binary.AppendUvarint(header[:0], uint64(i))
+        header = binary.AppendUvarint(header, uint64(len(data)))
+        if _, err := w.Write(header); err != nil {
+            return err
+        }
+        if _, err := chunkWriter.Write(data); err != nil {
+            return err
+        }
+        if err := chunkWriter.Close(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (stream Stream) Decode(r io.Reader, e interface{}) error {
+    var magic [len(streamMagic)]byte
+    n, err := io.ReadFull(r, magic[:])
+    if err == io.EOF {
+        return err
+    }
+    if err != nil || string(magic[:]) != streamMagic {
+        // not streamed; put back what was read.
+        if seeker, ok := r.(io.Seeker); ok {
+            if _, err := seeker.Seek(int64(-n), io.SeekCurrent); err != nil {
+                return err
+            }
+        } else {
+            r = io.MultiReader(bytes.NewReader(magic[:n]), r)
+        }
+        return stream.decoder(r).Decode(e)
+    }
+
+    br := asByteReader(r)
+    restLen, err := binary.ReadUvarint(br)
+    if err != nil {
+        return unexpected(err)
+    }
+    buf := GetBuffer()
+    defer PutBuffer(buf)
+    if _, err := io.CopyN(buf, br, int64(restLen)); err != nil {
+        return unexpected(err)
+    }
+    if err := stream.decoder(bytes.NewReader(buf.Bytes())).Decode(e); err != nil {
+        return err
+    }
+
+    s, ok := streamTarget(reflect.ValueOf(e))
+    if !ok {
+        return errors.New("labgob: streamed fields for a value that is not a struct")
+    }
+    count, err := binary.ReadUvarint(br)
+    if err != nil {
+        return unexpected(err)
+    }
+    for ; count > 0; count-- {
+        index, err := binary.ReadUvarint(br)
+        if err != nil {
+            return unexpected(err)
+        }
+        total, err := binary.ReadUvarint(br)
+        if err != nil {
+            return unexpected(err)
+        }
+        if index >= uint64(s.NumField()) || !s.Type().Field(int(index)).IsExported() ||
+            !isBytes(s.Type().Field(int(index)).Type) {
+            return fmt.Errorf("labgob: streamed field %d is not a []byte field of %v", index, s.Type())
+        }
+
+        chunkReader := &ChunkReader{r: br}
+        data, err := readField(br, chunkReader, total)
+        if err != nil {
+            return err
+        }
+        if extra, err := chunkReader.Read(magic[:1]); extra != 0 || err != io.EOF {
+            return fmt.Errorf("labgob: streamed field %d is longer than %d bytes", index, total)
+        }
+        s.Field(int(index)).SetBytes(data)
+    }
+    return nil
+}
@@ -0,0 +1,187 @@
+package labgob
+
+import (
+    "bytes"
+    "encoding/binary"
+    "io"
+    "reflect"
+    "testing"
+)
+
+type InstallSnapshotArgs struct {
+    Term              int
+    LastIncludedIndex int
+    Data              []byte
+    Small             []byte
+}
+
+func snapshotArgs(size int) InstallSnapshotArgs {
+    data := make([]byte, size)
+    for i := range data {
+        data[i] = byte(i * 31)
+    }
+    return InstallSnapshotArgs{Term: 4, LastIncludedIndex: 1000, Data: data, Small: []byte("abc")}
+}
+
+// hides the io.Seeker and io.ByteReader of a bytes.Reader.
+type plainReader struct {
+    r io.Reader
+}
+
+func (plain plainReader) Read(p []byte) (int, error) {
+    return plain.r.Read(p)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+    for _, size := range []int{0, 100, StreamThreshold, 3*ChunkSize + 17} {
+        for _, codec := range []Codec{Gob, JSON, MsgPack} {
+            in := snapshotArgs(size)
+            var buf bytes.Buffer
+            if err := EncodeStream(codec, &buf, &in); err != nil {
+                t.Fatalf("%v %d: encode: %v", codec.Name(), size, err)
+            }
+            streamed := bytes.HasPrefix(buf.Bytes(), []byte(streamMagic))
+            if streamed != (size >= StreamThreshold) {
+                t.Fatalf("%v %d: expected streamed to be %v", codec.Name(), size, !streamed)
+            }
+
+            for _, r := range []io.Reader{bytes.NewReader(buf.Bytes()), plainReader{bytes.NewReader(buf.Bytes())}} {
+                var out InstallSnapshotArgs
+                if err := DecodeStream(codec, r, &out); err != nil {
+                    t.Fatalf("%v %d: decode: %v", codec.Name(), size, err)
+                }
+                if size == 0 {
+                    // the codecs do not tell nil from empty.
+                    out.Data = in.Data
+                }
+                if !reflect.DeepEqual(in, out) {
+                    t.Fatalf("%v %d: round trip changed the value", codec.Name(), size)
+                }
+            }
+        }
+    }
+}
+
+func TestStreamSmallIsPlain(t *testing.T) {
+    // exactly what the codec alone writes, so either side
+    // can decode the other's small messages.
+    in := snapshotArgs(10)
+    var streamed, plain bytes.Buffer
+    EncodeStream(Gob, &streamed, in)
+    NewEncoder(&plain).Encode(in)
+    if !bytes.Equal(streamed.Bytes(), plain.Bytes()) {
+        t.Fatalf("expected a small value to be encoded as by the codec")
+    }
+
+    // a one-byte message, shorter than the magic.
+    var buf bytes.Buffer
+    EncodeStream(MsgPack, &buf, 0)
+    var x int = 0
+    if err := DecodeStream(MsgPack, plainReader{&buf}, &x); err != nil || x != 0 {
+        t.Fatalf("expected 0, got %v, %v", x, err)
+    }
+}
+
+func TestStreamCorrupt(t *testing.T) {
+    in := snapshotArgs(2 * StreamThreshold)
+    var buf bytes.Buffer
+    EncodeStream(Gob, &buf, in)
+    data := buf.Bytes()
+
+    var out InstallSnapshotArgs
+    if err := DecodeStream(Gob, bytes.NewReader(data[:len(data)-100]), &out); err != io.ErrUnexpectedEOF {
+        t.Fatalf("expected a truncated stream to fail, got %v", err)
+    }
+}
+
+func TestStreamHugeLength(t *testing.T) {
+    // a header claiming 2^62 bytes for Data, then a few bytes.
+    var buf bytes.Buffer
+    EncodeStream(Gob, &buf, snapshotArgs(StreamThreshold))
+    data := buf.Bytes()
+    restLen, n := binary.Uvarint(data[len(streamMagic):])
+    header := len(streamMagic) + n + int(restLen) + 1 // and the field count
+    forged := append([]byte{}, data[:header]...)
+    forged = binary.AppendUvarint(forged, 2) // Data's field index
+    forged = binary.AppendUvarint(forged, 1<<62)
+    forged = append(forged, 3, 1, 2, 3, 0)
+
+    for _, r := range []io.Reader{bytes.NewReader(forged), plainReader{bytes.NewReader(forged)}} {
+        var out InstallSnapshotArgs
+        var err error
+        n := allocated(func() {
+            err = DecodeStream(Gob, r, &out)
+        })
+        if err == nil {
+            t.Fatalf("expected a forged length to fail")
+        }
+        if err != io.ErrUnexpectedEOF {
+            t.Fatalf("expected the stream to run short, got %v", err)
+        }
+        if n > 4<<20 {
+            t.Fatalf("%d bytes allocated for a %d-byte stream", n, len(forged))
+        }
+    }
+}
+
+func TestChunks(t *testing.T) {
+    var buf bytes.Buffer
+    chunkWriter := NewChunkWriter(&buf)
+    chunkWriter.Write(make([]byte, ChunkSize+1))
+    chunkWriter.Write([]byte("tail"))
+    chunkWriter.Close()
+    buf.WriteString("after")
+
+    chunkReader := NewChunkReader(&buf)
+    data, err := io.ReadAll(chunkReader)
+    if err != nil || len(data) != ChunkSize+5 || string(data[ChunkSize+1:]) != "tail" {
+        t.Fatalf("expected %d bytes ending in tail, got %d, %v", ChunkSize+5, len(data), err)
+    }
+    if rest, _ := io.ReadAll(&buf); string(rest) != "after" {
+        t.Fatalf("expected the reader to stop at the end of the chunks, got %q", rest)
+    }
+}
+
+//
+// a 4MB snapshot through the codec alone, and streamed.
+//
+
+func BenchmarkSnapshotEncode(b *testing.B) {
+    args := snapshotArgs(4 << 20)
+    b.Run("codec", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            NewEncoder(new(bytes.Buffer)).Encode(args)
+        }
+    })
+    b.Run("stream", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            buf := GetBuffer()
+            EncodeStream(Gob, buf, args)
+            TakeBytes(buf)
+        }
+    })
+}
+
+func BenchmarkSnapshotDecode(b *testing.B) {
+    args := snapshotArgs(4 << 20)
+    var plain, streamed bytes.Buffer
+    NewEncoder(&plain).Encode(args)
+    EncodeStream(Gob, &streamed, args)
+
+    b.Run("codec", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            var out InstallSnapshotArgs
+            NewDecoder(bytes.NewReader(plain.Bytes())).Decode(&out)
+        }
+    })
+    b.Run("stream", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            var out InstallSnapshotArgs
+            DecodeStream(Gob, bytes.NewReader(streamed.Bytes()), &out)
+        }
+    })
+}
@@ -0,0 +1,314 @@
+package labgob
+
+//
+// walk a value all the way down, through structs, pointers,
+// slices, arrays, maps and interfaces, to find non-default values
+// before a Decode and unregistered interface types before an
+// Encode. each problem comes with its exact path, such as
+// Entries[3].Command.Key. what a type can hold is worked out once
+// and cached, so that walking a large []byte or a struct with no
+// exported fields costs next to nothing on hot paths.
+//
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// how deep the checks go, unless SetMaxDepth() says otherwise.
+const DefaultMaxDepth = 64
+
+// stop after this many problems; one stale reply can
+// otherwise produce thousands.
+const maxProblems = 16
+
+type fieldInfo struct {
+    index int
+    name  string
+}
+
+// what labgob needs to know about a type to walk its values.
+type typeInfo struct {
+    fields         []fieldInfo // exported fields of a struct
+    holdsData      bool        // can hold a value the codec would send
+    holdsInterface bool        // can hold an interface value
+}
+
+var typeInfoCache sync.Map // reflect.Type -> *typeInfo
+
+func infoOf(t reflect.Type) *typeInfo {
+    if info, ok := typeInfoCache.Load(t); ok {
+        return info.(*typeInfo)
+    }
+    info := &typeInfo{}
+    if t.Kind() == reflect.Struct {
+        for i := 0; i < t.NumField(); i++ {
+            if f := t.Field(i); f.IsExported() {
+                info.fields = append(info.fields, fieldInfo{i, f.Name})
+            }
+        }
+    }
+    // only t's own answer is cached: ones for types inside a
+    // recursive type are incomplete until the recursion unwinds.
+    info.holdsData, info.holdsInterface = holds(t, map[reflect.Type]bool{})
+    typeInfoCache.Store(t, info)
+    return info
+}
+
+func holds(t reflect.Type, visiting map[reflect.Type]bool) (data bool, iface bool) {
+    if visiting[t] {
+        return false, false
+    }
+    visiting[t] = true
+    defer delete(visiting, t)
+
+    switch t.Kind() {
+    case reflect.Interface:
+        return true, true
+    case reflect.Ptr, reflect.Slice, reflect.Array:
+        return holds(t.Elem(), visiting)
+    case reflect.Map:
+        _, keyIface := holds(t.Key(), visiting)
+        _, elemIface := holds(t.Elem(), visiting)
+        // a map entry is data even if both halves are empty.
+        return true, keyIface || elemIface
+    case reflect.Struct:
+        for i := 0; i < t.NumField(); i++ {
+            if f := t.Field(i); f.IsExported() {
+                fieldData, fieldIface := holds(f.Type, visiting)
+                data = data || fieldData
+                iface = iface || fieldIface
+            }
+        }
+        return data, iface
+    case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
+        return false, false
+    default:
+        return true, false
+    }
+}
+
+// a path such as Entries[3].Command.Key, built up and torn
+// down as the walk goes, and only turned into a string when
+// there is a problem to report.
+type path []string
+
+func (p path) String() string {
+    return strings.TrimPrefix(strings.Join(p, ""), ".")
+}
+
+type visitKey struct {
+    ptr uintptr
+    typ reflect.Type
+}
+
+type walk struct {
+    maxDepth int
+    path     path
+    root     reflect.Type
+    visited  map[visitKey]bool // pointers, maps and slices already walked, to stop at cycles
+    problems []*CheckError
+}
+
+func makeWalk(value reflect.Value, maxDepth int) *walk {
+    root := value.Type()
+    for root.Kind() == reflect.Ptr {
+        root = root.Elem()
+    }
+    return &walk{maxDepth: maxDepth, root: root, visited: map[visitKey]bool{}}
+}
+
+func (walk *walk) done() bool {
+    return len(walk.problems) >= maxProblems
+}
+
+func (walk *walk) report(problem CheckProblem, t reflect.Type) {
+    what := walk.path.String()
+    if what == "" {
+        what = walk.root.Name()
+        if what == "" {
+            what = walk.root.String()
+        }
+    }
+    walk.problems = append(walk.problems, &CheckError{Problem: problem, Path: what, Type: t})
+}
+
+// whether value was walked before; if not, it is now.
+func (walk *walk) seen(value reflect.Value) bool {
+    key := visitKey{value.Pointer(), value.Type()}
+    if walk.visited[key] {
+        return true
+    }
+    walk.visited[key] = true
+    return false
+}
+
+func fieldSegment(name string) string {
+    return "." + name
+}
+
+func indexSegment(i int) string {
+    return fmt.Sprintf("[%d]", i)
+}
+
+func keySegment(key reflect.Value) string {
+    if key.Kind() == reflect.String {
+        return fmt.Sprintf("[%q]", key.String())
+    }
+    return fmt.Sprintf("[%v]", key)
+}
+
+// a map's keys in a stable order, so that paths do not
+// change from one run to the next.
+func sortedKeys(value reflect.Value) []reflect.Value {
+    keys := value.MapKeys()
+    sort.Slice(keys, func(i, j int) bool {
+        return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+    })
+    return keys
+}
+
+// report the non-default values in value, as deep as maxDepth,
+// and say whether there were any. a non-empty slice, a non-empty
+// map or a non-nil interface whose contents are all default is
+// itself reported, since gob would leave it in place.
+func (walk *walk) nonDefault(value reflect.Value, depth int) bool {
+    if depth == 0 || walk.done() {
+        return false
+    }
+    t := value.Type()
+    info := infoOf(t)
+    if !info.holdsData {
+        return false
+    }
+
+    switch t.Kind() {
+    case reflect.Struct:
+        found := false
+        for _, f := range info.fields {
+            walk.path = append(walk.path, fieldSegment(f.name))
+            found = walk.nonDefault(value.Field(f.index), depth-1) || found
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+        return found
+    case reflect.Ptr:
+        if value.IsNil() || walk.seen(value) {
+            return false
+        }
+        return walk.nonDefault(value.Elem(), depth-1)
+    case reflect.Interface:
+        if value.IsNil() {
+            return false
+        }
+        if !walk.nonDefault(value.Elem(), depth-1) {
+            walk.report(NonDefaultValue, nil)
+        }
+        return true
+    case reflect.Slice, reflect.Array:
+        if t.Kind() == reflect.Slice && (value.Len() == 0 || walk.seen(value)) {
+            return false
+        }
+        if t.Elem().Kind() == reflect.Uint8 {
+            // one problem for the bytes, not one per byte.
+            if t.Kind() == reflect.Array && value.IsZero() {
+                return false
+            }
+            walk.report(NonDefaultValue, nil)
+            return true
+        }
+        found := false
+        for i := 0; i < value.Len() && !walk.done(); i++ {
+            walk.path = append(walk.path, indexSegment(i))
+            found = walk.nonDefault(value.Index(i), depth-1) || found
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+        if !found && t.Kind() == reflect.Slice {
+            walk.report(NonDefaultValue, nil)
+            found = true
+        }
+        return found
+    case reflect.Map:
+        if value.IsNil() || value.Len() == 0 || walk.seen(value) {
+            return false
+        }
+        for _, key := range sortedKeys(value) {
+            if walk.done() {
+                break
+            }
+            walk.path = append(walk.path, keySegment(key))
+            // the entry itself will linger, whatever its value.
+            if !walk.nonDefault(value.MapIndex(key), depth-1) {
+                walk.report(NonDefaultValue, nil)
+            }
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+        return true
+    default:
+        if value.IsZero() {
+            return false
+        }
+        walk.report(NonDefaultValue, nil)
+        return true
+    }
+}
+
+// report values in interfaces whose types were never
+// registered, as deep as maxDepth.
+func (walk *walk) interfaces(value reflect.Value, depth int) {
+    if depth == 0 || walk.done() {
+        return
+    }
+    t := value.Type()
+    info := infoOf(t)
+    if !info.holdsInterface {
+        return
+    }
+
+    switch t.Kind() {
+    case reflect.Struct:
+        for _, f := range info.fields {
+            walk.path = append(walk.path, fieldSegment(f.name))
+            walk.interfaces(value.Field(f.index), depth-1)
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+    case reflect.Ptr:
+        if !value.IsNil() && !walk.seen(value) {
+            walk.interfaces(value.Elem(), depth-1)
+        }
+    case reflect.Interface:
+        if value.IsNil() {
+            return
+        }
+        elem := value.Elem()
+        if _, ok := registeredName(elem.Type()); !ok {
+            walk.report(UnregisteredInterface, elem.Type())
+            return
+        }
+        walk.interfaces(elem, depth-1)
+    case reflect.Slice, reflect.Array:
+        if t.Kind() == reflect.Slice && (value.IsNil() || walk.seen(value)) {
+            return
+        }
+        for i := 0; i < value.Len() && !walk.done(); i++ {
+            walk.path = append(walk.path, indexSegment(i))
+            walk.interfaces(value.Index(i), depth-1)
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+    case reflect.Map:
+        if value.IsNil() || walk.seen(value) {
+            return
+        }
+        for _, key := range sortedKeys(value) {
+            if walk.done() {
+                break
+            }
+            walk.path = append(walk.path, keySegment(key))
+            walk.interfaces(key, depth-1)
+            walk.interfaces(value.MapIndex(key), depth-1)
+            walk.path = walk.path[:len(walk.path)-1]
+        }
+    }
+}
@@ -0,0 +1,143 @@
+package labgob
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "reflect"
+    "testing"
+)
+
+type KeyCommand struct {
+    Key   string
+    Value string
+}
+
+type WalkEntry struct {
+    Term    int
+    Command interface{}
+}
+
+type WalkReply struct {
+    Term     int
+    Entries  []WalkEntry
+    Votes    [3]int
+    Peers    map[string]int
+    Snapshot []byte
+    Next     *WalkReply
+}
+
+type WalkNode struct {
+    Value int
+    Next  *WalkNode
+}
+
+// the paths of the problems Decode would report for value.
+func nonDefaultPaths(t *testing.T, value interface{}, maxDepth int) []string {
+    var buf bytes.Buffer
+    decoder := NewDecoder(&buf)
+    decoder.SetStrict(true)
+    decoder.SetMaxDepth(maxDepth)
+    err := decoder.Decode(value)
+    if err == io.EOF {
+        // no problems, so it went on to decode an empty buffer.
+        return []string{}
+    }
+
+    paths := []string{}
+    for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+        var checkError *CheckError
+        if !errors.As(err, &checkError) || checkError.Problem != NonDefaultValue {
+            t.Fatalf("expected non-default value errors, got %v", err)
+        }
+        paths = append(paths, checkError.Path)
+    }
+    return paths
+}
+
+func TestWalkPaths(t *testing.T) {
+    Register(KeyCommand{})
+
+    tests := []struct {
+        name  string
+        reply WalkReply
+        paths []string
+    }{
+        {"default", WalkReply{}, []string{}},
+        {"empty slice and map", WalkReply{Entries: []WalkEntry{}, Peers: map[string]int{}}, []string{}},
+        {"field", WalkReply{Term: 2}, []string{"Term"}},
+        {
+            "deep in a slice",
+            WalkReply{Entries: []WalkEntry{{}, {}, {}, {Command: KeyCommand{Key: "x"}}}},
+            []string{"Entries[3].Command.Key"},
+        },
+        {"default elements", WalkReply{Entries: make([]WalkEntry, 2)}, []string{"Entries"}},
+        {"interface holding default", WalkReply{Entries: []WalkEntry{{Command: 0}}}, []string{"Entries[0].Command"}},
+        {"array", WalkReply{Votes: [3]int{0, 0, 7}}, []string{"Votes[2]"}},
+        {"map", WalkReply{Peers: map[string]int{"b": 0, "a": 1}}, []string{`Peers["a"]`, `Peers["b"]`}},
+        {"bytes", WalkReply{Snapshot: []byte{0, 0, 0}}, []string{"Snapshot"}},
+        {"pointer", WalkReply{Next: &WalkReply{Entries: []WalkEntry{{Term: 1}}}}, []string{"Next.Entries[0].Term"}},
+    }
+
+    for _, test := range tests {
+        reply := test.reply
+        paths := nonDefaultPaths(t, &reply, 0)
+        if !reflect.DeepEqual(paths, test.paths) {
+            t.Errorf("%v: expected %v, got %v", test.name, test.paths, paths)
+        }
+    }
+}
+
+func TestWalkCycle(t *testing.T) {
+    node := &WalkNode{}
+    node.Next = &WalkNode{Value: 1, Next: node}
+
+    paths := nonDefaultPaths(t, node, 0)
+    if !reflect.DeepEqual(paths, []string{"Next.Value"}) {
+        t.Fatalf("expected [Next.Value], got %v", paths)
+    }
+}
+
+func TestWalkMaxDepth(t *testing.T) {
+    // Value is five steps down: Next, *, Next, *, Value.
+    node := &WalkNode{Next: &WalkNode{Next: &WalkNode{Value: 1}}}
+
+    if paths := nonDefaultPaths(t, node, 4); len(paths) != 0 {
+        t.Fatalf("expected nothing within depth 4, got %v", paths)
+    }
+    if paths := nonDefaultPaths(t, node, 0); !reflect.DeepEqual(paths, []string{"Next.Next.Value"}) {
+        t.Fatalf("expected [Next.Next.Value], got %v", paths)
+    }
+}
+
+func TestWalkManyProblems(t *testing.T) {
+    reply := WalkReply{}
+    for i := 0; i < 1000; i++ {
+        reply.Entries = append(reply.Entries, WalkEntry{Term: i + 1})
+    }
+    if paths := nonDefaultPaths(t, &reply, 0); len(paths) != maxProblems {
+        t.Fatalf("expected %d problems, got %d", maxProblems, len(paths))
+    }
+}
+
+func TestWalkInterfacePath(t *testing.T) {
+    var buf bytes.Buffer
+    encoder := NewEncoder(&buf)
+    encoder.SetStrict(true)
+
+    reply := WalkReply{Entries: []WalkEntry{{}, {Command: UnregisteredCommand{}}}}
+    var checkError *CheckError
+    if err := encoder.Encode(reply); !errors.As(err, &checkError) || checkError.Path != "Entries[1].Command" {
+        t.Fatalf("expected an unregistered type at Entries[1].Command, got %v", err)
+    }
+}
+
+func BenchmarkDecodeCheck(b *testing.B) {
+    // a fresh reply with a large snapshot, as on the hot path.
+    reply := WalkReply{Entries: make([]WalkEntry, 0, 100)}
+    walk := makeWalk(reflect.ValueOf(&reply), DefaultMaxDepth)
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        walk.nonDefault(reflect.ValueOf(&reply), DefaultMaxDepth)
+    }
+}
@@ -0,0 +1,101 @@
+package labrpc
+
+import (
+    "context"
+    "log"
+)
+
+// an asynchronous RPC, in the style of net/rpc.
+type Call struct {
+    ServiceMethod string      // e.g. "Raft.AppendEntries"
+    Args          interface{} // the argument to the handler
+    Reply         interface{} // the reply from the handler, valid if Ok
+    Ok            bool        // false means that no reply was received
+    Error         error       // why not, as returned by Invoke
+    Done          chan *Call  // receives *Call when the RPC completes
+}
+
+func (call *Call) done() {
+    select {
+    case call.Done <- call:
+    default:
+        // never block the RPC goroutine; as in net/rpc, it is
+        // the caller's job to make Done big enough.
+        log.Printf("labrpc: discarding Call reply due to insufficient Done chan capacity\n")
+    }
+}
+
+// start an RPC without waiting for it to complete.
+// the returned Call is sent on done when the RPC finishes;
+// if done is nil, a new channel is allocated.
+func (clientEnd *ClientEnd) Go(serviceMethod string, args interface{}, reply interface{}, done chan *Call) *Call {
+    return clientEnd.GoContext(context.Background(), serviceMethod, args, reply, done)
+}
+
+// like Go, but the RPC gives up when ctx is done, see Invoke.
+func (clientEnd *ClientEnd) GoContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}, done chan *Call) *Call {
+    if done == nil {
+        done = make(chan *Call, 1)
+    } else if cap(done) == 0 {
+        log.Panic("labrpc: done channel is unbuffered")
+    }
+
+    call := &Call{}
+    call.ServiceMethod = serviceMethod
+    call.Args = args
+    call.Reply = reply
+    call.Done = done
+
+    go func() {
+        call.Error = clientEnd.Invoke(ctx, serviceMethod, args, reply)
+        call.Ok = call.Error == nil
+        call.done()
+    }()
+
+    return call
+}
+
+// call serviceMethod on every end in parallel, and return as soon
+// as quorum of them have replied successfully, or as soon as so many
+// have failed that quorum can no longer be reached, or when ctx is done.
+// newReply is called once per end to make a fresh reply.
+// calls[i] is the completed Call for ends[i], or nil if that end had
+// not (yet) replied successfully. RPCs still outstanding on return
+// are cancelled.
+func Broadcast(
+    ctx context.Context, ends []*ClientEnd, serviceMethod string,
+    args interface{}, newReply func() interface{}, quorum int,
+) (calls []*Call, ok bool) {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    calls = make([]*Call, len(ends))
+    if quorum <= 0 {
+        return calls, true
+    }
+
+    done := make(chan *Call, len(ends))
+    index := map[*Call]int{}
+    for i, end := range ends {
+        call := end.GoContext(ctx, serviceMethod, args, newReply(), done)
+        index[call] = i
+    }
+
+    succeeded := 0
+    failed := 0
+    for succeeded < quorum && failed <= len(ends)-quorum {
+        select {
+        case call := <-done:
+            if call.Ok {
+                calls[index[call]] = call
+                succeeded += 1
+            } else {
+                failed += 1
+            }
+        case <-ctx.Done():
+            return calls, false
+        }
+    }
+
+    return calls, succeeded >= quorum
+}
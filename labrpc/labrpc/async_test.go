@@ -0,0 +1,116 @@
+package labrpc
+
+import (
+    "context"
+    "fmt"
+    "runtime"
+    "testing"
+    "time"
+)
+
+func TestGo(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    clientEnd := network.MakeEnd("end-42")
+
+    server := MakeServer()
+    server.AddService(MakeService(&JunkServer{}))
+    network.AddServer("server-42", server)
+
+    network.Connect("end-42", "server-42")
+    network.Enable("end-42", true)
+
+    done := make(chan *Call, 10)
+    for i := 0; i < 10; i++ {
+        var reply string
+        clientEnd.Go("JunkServer.HandlerIntToString", i, &reply, done)
+    }
+
+    seen := map[string]bool{}
+    for i := 0; i < 10; i++ {
+        call := <-done
+        if !call.Ok {
+            t.Fatalf("expected call %v to succeed", call.Args)
+        }
+        reply := *call.Reply.(*string)
+        if reply != fmt.Sprint(call.Args) {
+            t.Fatalf("expected reply to be %v, got %s", call.Args, reply)
+        }
+        seen[reply] = true
+    }
+    if len(seen) != 10 {
+        t.Fatalf("expected 10 distinct replies, got %d", len(seen))
+    }
+}
+
+func makeBroadcastNetwork(n int) (*Network, []*ClientEnd) {
+    network := MakeNetwork()
+
+    ends := []*ClientEnd{}
+    for i := 0; i < n; i++ {
+        endName := fmt.Sprintf("end-%d", i)
+        serverName := fmt.Sprintf("server-%d", i)
+
+        ends = append(ends, network.MakeEnd(endName))
+
+        server := MakeServer()
+        server.AddService(MakeService(&JunkServer{}))
+        network.AddServer(serverName, server)
+
+        network.Connect(endName, serverName)
+        network.Enable(endName, true)
+    }
+
+    return network, ends
+}
+
+func TestBroadcastQuorum(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(5)
+    defer network.Cleanup()
+
+    // two of five servers are unreachable and would
+    // keep a sequential caller waiting for seconds.
+    network.LongDelays(true)
+    network.Enable("end-3", false)
+    network.Enable("end-4", false)
+
+    start := time.Now()
+    calls, ok := Broadcast(context.Background(), ends, "JunkServer.HandlerIntToString", 7,
+        func() interface{} { return new(string) }, 3)
+    if !ok {
+        t.Fatalf("expected quorum of 3 to be reached")
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Fatalf("Broadcast took %v, expected it to return at quorum", elapsed)
+    }
+
+    for i := 0; i < 3; i++ {
+        if calls[i] == nil || *calls[i].Reply.(*string) != "7" {
+            t.Fatalf("expected reply from end-%d", i)
+        }
+    }
+    if calls[3] != nil || calls[4] != nil {
+        t.Fatalf("expected no reply from disabled ends")
+    }
+}
+
+func TestBroadcastNoQuorum(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(3)
+    defer network.Cleanup()
+
+    network.Enable("end-1", false)
+    network.Enable("end-2", false)
+
+    _, ok := Broadcast(context.Background(), ends, "JunkServer.HandlerIntToString", 7,
+        func() interface{} { return new(string) }, 2)
+    if ok {
+        t.Fatalf("expected quorum of 2 to be unreachable")
+    }
+}
@@ -0,0 +1,103 @@
+package labrpc
+
+//
+// limits on how much a Network will carry, so that a Raft that
+// ships its whole log in every heartbeat is slowed down, rejected,
+// or flagged by the test, rather than passing unnoticed.
+//
+
+import (
+    "sync/atomic"
+    "time"
+)
+
+// limit each link, i.e. each ClientEnd, to bytesPerSecond.
+// a request is held back until the link has finished sending
+// the requests ahead of it and then its own len(req.args) bytes.
+// 0 means unlimited.
+func (network *Network) Bandwidth(bytesPerSecond int64) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    network.bandwidth = bytesPerSecond
+}
+
+// fail any RPC whose encoded request or reply is larger than
+// bytes, as if it had been lost. 0 means unlimited.
+func (network *Network) MaxMessageSize(bytes int) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    network.maxMessageSize = bytes
+}
+
+// call exceeded, once, when the total bytes carried by the network
+// (as reported by GetTotalBytes()) first exceed limit, e.g.
+//
+//    network.ByteBudget(1<<20, func(total int64) {
+//        t.Errorf("sent %v bytes, expected at most 1MB", total)
+//    })
+//
+// exceeded runs on a goroutine of its own, so that it cannot hold
+// up the network; it must therefore not call t.Fatal(), which only
+// works on the test's goroutine. a test can instead check
+// BudgetExceeded() once it is done. 0 means unlimited.
+func (network *Network) ByteBudget(limit int64, exceeded func(total int64)) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    network.byteBudget = limit
+    network.budgetExceeded = exceeded
+    network.overBudget = 0
+}
+
+// the total bytes carried when the budget was first exceeded,
+// or 0 if it has not been.
+func (network *Network) BudgetExceeded() int64 {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    return network.overBudget
+}
+
+func (network *Network) addBytes(n int) {
+    total := atomic.AddInt64(&network.bytes, int64(n))
+
+    network.mu.Lock()
+    defer network.mu.Unlock()
+    if network.byteBudget > 0 && total > network.byteBudget && network.overBudget == 0 {
+        // only once.
+        network.overBudget = total
+        if network.budgetExceeded != nil {
+            go network.budgetExceeded(total)
+        }
+    }
+}
+
+func (network *Network) tooLarge(n int) bool {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    return network.maxMessageSize > 0 && n > network.maxMessageSize
+}
+
+// how long a message of n bytes waits before it has been
+// completely sent on endName's link.
+func (network *Network) transmitDelay(endName interface{}, n int) time.Duration {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    if network.bandwidth <= 0 {
+        return 0
+    }
+
+    now := time.Now()
+    free := network.linkFree[endName]
+    if free.Before(now) {
+        free = now
+    }
+    free = free.Add(time.Duration(int64(n) * int64(time.Second) / network.bandwidth))
+    network.linkFree[endName] = free
+
+    return free.Sub(now)
+}
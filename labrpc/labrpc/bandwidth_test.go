@@ -0,0 +1,125 @@
+package labrpc
+
+import (
+    "runtime"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func (junkServer *JunkServer) HandlerRepeat(args int, reply *string) {
+    *reply = strings.Repeat("x", args)
+}
+
+func TestBandwidth(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    network.Bandwidth(10000)
+
+    // ten 1000-byte requests on one 10000 byte/s link take about a second,
+    // even when they are sent in parallel.
+    big := strings.Repeat("x", 1000)
+    start := time.Now()
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var reply int
+            ends[0].Call("JunkServer.HandlerStringToInt", big, &reply)
+        }()
+    }
+    wg.Wait()
+
+    if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+        t.Fatalf("expected bandwidth limit to take about 1s, took %v", elapsed)
+    }
+
+    // small requests are hardly delayed.
+    network.Bandwidth(0)
+    start = time.Now()
+    var reply int
+    ends[0].Call("JunkServer.HandlerStringToInt", big, &reply)
+    if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+        t.Fatalf("expected unlimited bandwidth to be fast, took %v", elapsed)
+    }
+}
+
+func TestMaxMessageSize(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    network.MaxMessageSize(100)
+
+    var reply int
+    if ends[0].Call("JunkServer.HandlerStringToInt", strings.Repeat("x", 1000), &reply) {
+        t.Fatalf("expected oversized request to be rejected")
+    }
+    if !ends[0].Call("JunkServer.HandlerStringToInt", "42", &reply) || reply != 42 {
+        t.Fatalf("expected small request to succeed, got %d", reply)
+    }
+
+    // a reply can be too large as well.
+    var str string
+    if ends[0].Call("JunkServer.HandlerRepeat", 1000, &str) {
+        t.Fatalf("expected oversized reply to be rejected")
+    }
+    if !ends[0].Call("JunkServer.HandlerRepeat", 10, &str) || len(str) != 10 {
+        t.Fatalf("expected small reply to succeed, got %q", str)
+    }
+}
+
+func TestByteBudget(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    hook := make(chan int64, 10)
+    network.ByteBudget(2000, func(total int64) {
+        hook <- total
+    })
+
+    big := strings.Repeat("x", 1000)
+    for i := 0; i < 5; i++ {
+        var reply int
+        ends[0].Call("JunkServer.HandlerStringToInt", big, &reply)
+    }
+
+    if exceededAt := network.BudgetExceeded(); exceededAt <= 2000 {
+        t.Fatalf("expected the total above budget to be recorded, got %d", exceededAt)
+    }
+    if exceededAt := <-hook; exceededAt != network.BudgetExceeded() {
+        t.Fatalf("expected hook to see %d, got %d", network.BudgetExceeded(), exceededAt)
+    }
+    select {
+    case <-hook:
+        t.Fatalf("expected budget hook to be called once")
+    case <-time.After(50 * time.Millisecond):
+    }
+}
+
+func TestByteBudgetGoexit(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    // what t.Fatalf() does off the test's goroutine.
+    network.ByteBudget(10, func(total int64) {
+        runtime.Goexit()
+    })
+
+    for i := 0; i < 3; i++ {
+        var reply int
+        if !ends[0].Call("JunkServer.HandlerStringToInt", "1234567890", &reply) {
+            t.Fatalf("expected calls to go on after the hook exits")
+        }
+    }
+}
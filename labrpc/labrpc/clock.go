@@ -0,0 +1,192 @@
+package labrpc
+
+//
+// simulated per-server clocks and stop-the-world pauses, so that
+// lease-based reads and leader leases can be tested against servers
+// whose clocks disagree, or that freeze as in a long GC pause while
+// the rest of the network carries on.
+//
+// a handler that takes a context.Context finds its server's clock
+// with ClockFromContext(ctx); other code can use Server.Clock().
+//
+// Go cannot stop a goroutine from the outside, so a pause freezes
+// a running handler the next time it consults its server's Clock.
+//
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+)
+
+type clockKey struct{}
+
+// a server's view of the time. it may be offset from the real
+// clock (skew), and may run faster or slower than it (drift).
+type Clock struct {
+    mu     sync.Mutex
+    origin time.Time // real time at which base was taken
+    base   time.Time // this clock's time at origin
+    drift  float64   // e.g. 0.01 gains 10ms every real second
+    paused func()    // blocks while the clock's server is paused; nil for realClock
+}
+
+func makeClock() *Clock {
+    clock := &Clock{}
+    clock.origin = time.Now()
+    clock.base = clock.origin
+    return clock
+}
+
+// the real clock, for code that runs outside any server.
+var realClock = makeClock()
+
+// block while the clock's server is paused.
+func (clock *Clock) waitWhilePaused() {
+    if clock.paused != nil {
+        clock.paused()
+    }
+}
+
+func (clock *Clock) Now() time.Time {
+    clock.waitWhilePaused()
+    clock.mu.Lock()
+    defer clock.mu.Unlock()
+    return clock.now(time.Now())
+}
+
+func (clock *Clock) now(real time.Time) time.Time {
+    elapsed := real.Sub(clock.origin)
+    return clock.base.Add(elapsed + time.Duration(float64(elapsed)*clock.drift))
+}
+
+func (clock *Clock) Since(t time.Time) time.Duration {
+    return clock.Now().Sub(t)
+}
+
+// the real time it takes this clock to advance by d.
+func (clock *Clock) realDuration(d time.Duration) time.Duration {
+    clock.mu.Lock()
+    defer clock.mu.Unlock()
+    return time.Duration(float64(d) / (1 + clock.drift))
+}
+
+// sleep until this clock has advanced by d.
+func (clock *Clock) Sleep(d time.Duration) {
+    clock.waitWhilePaused()
+    time.Sleep(clock.realDuration(d))
+    clock.waitWhilePaused()
+}
+
+// a channel that receives this clock's time once it has advanced by d.
+func (clock *Clock) After(d time.Duration) <-chan time.Time {
+    ch := make(chan time.Time, 1)
+    time.AfterFunc(clock.realDuration(d), func() {
+        ch <- clock.Now()
+    })
+    return ch
+}
+
+// from now on, run skew ahead of (or, if negative, behind) real time.
+func (clock *Clock) setSkew(skew time.Duration) {
+    clock.mu.Lock()
+    defer clock.mu.Unlock()
+
+    real := time.Now()
+    clock.origin = real
+    clock.base = real.Add(skew)
+}
+
+// from now on, gain drift seconds per real second; the clock
+// does not jump.
+func (clock *Clock) setDrift(drift float64) {
+    if drift <= -1 {
+        // the clock would stand still or run backwards.
+        panic(fmt.Sprintf("labrpc: clock drift %v is not above -1", drift))
+    }
+    clock.mu.Lock()
+    defer clock.mu.Unlock()
+
+    real := time.Now()
+    clock.base = clock.now(real)
+    clock.origin = real
+    clock.drift = drift
+}
+
+// the Clock of the server running a handler, or one that tells
+// real time if ctx does not come from a handler.
+func ClockFromContext(ctx context.Context) *Clock {
+    if clock, ok := ctx.Value(clockKey{}).(*Clock); ok {
+        return clock
+    }
+    return realClock
+}
+
+func (server *Server) Clock() *Clock {
+    return server.clock
+}
+
+// block while the server is paused, or until it crashes.
+func (server *Server) waitWhilePaused() {
+    for {
+        server.mu.Lock()
+        wait := time.Until(server.pausedUntil)
+        ctx := server.ctx
+        server.mu.Unlock()
+
+        if wait <= 0 {
+            return
+        }
+        select {
+        case <-time.After(wait):
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// set serverName's clock to run skew ahead of real time
+// (behind, if negative).
+func (network *Network) SetClockSkew(serverName interface{}, skew time.Duration) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    if server := network.servers[serverName]; server != nil {
+        server.clock.setSkew(skew)
+    }
+}
+
+// make serverName's clock gain drift seconds every real second,
+// e.g. 0.05 runs 5% fast and -0.05 runs 5% slow. panics unless
+// drift > -1, since the clock would otherwise stop or run backwards.
+func (network *Network) SetClockDrift(serverName interface{}, drift float64) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    if server := network.servers[serverName]; server != nil {
+        server.clock.setDrift(drift)
+    }
+}
+
+// freeze serverName for d, as a long garbage-collection pause
+// would: no handler starts and no reply leaves the server until
+// the pause is over. a handler already running freezes the next
+// time it calls Now(), Since(), Sleep() or After() on its server's
+// Clock; one that never does runs on, though its reply is still
+// held back. other servers are unaffected.
+func (network *Network) PauseServer(serverName interface{}, d time.Duration) {
+    network.mu.Lock()
+    server := network.servers[serverName]
+    network.mu.Unlock()
+
+    if server == nil {
+        return
+    }
+
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    if until := time.Now().Add(d); until.After(server.pausedUntil) {
+        server.pausedUntil = until
+    }
+}
@@ -0,0 +1,177 @@
+package labrpc
+
+import (
+    "context"
+    "runtime"
+    "testing"
+    "time"
+)
+
+type ClockServer struct{}
+
+func (clockServer *ClockServer) HandlerNow(ctx context.Context, args int, reply *int64) {
+    *reply = ClockFromContext(ctx).Now().UnixNano()
+}
+
+// the real time after each of n ticks of 10ms on the server's clock.
+func (clockServer *ClockServer) HandlerTicks(ctx context.Context, n int, reply *[]int64) {
+    clock := ClockFromContext(ctx)
+    for i := 0; i < n; i++ {
+        clock.Sleep(10 * time.Millisecond)
+        *reply = append(*reply, time.Now().UnixNano())
+    }
+}
+
+func makeClockNetwork(n int) (*Network, []*ClientEnd, []*Server) {
+    network := MakeNetwork()
+    ends := []*ClientEnd{}
+    servers := []*Server{}
+    for i := 0; i < n; i++ {
+        endName := i
+        serverName := 100 + i
+
+        ends = append(ends, network.MakeEnd(endName))
+
+        server := MakeServer()
+        server.AddService(MakeService(&ClockServer{}))
+        server.AddService(MakeService(&JunkServer{}))
+        network.AddServer(serverName, server)
+        servers = append(servers, server)
+
+        network.Connect(endName, serverName)
+        network.Enable(endName, true)
+    }
+    return network, ends, servers
+}
+
+func TestClockSkew(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends, _ := makeClockNetwork(2)
+    defer network.Cleanup()
+
+    network.SetClockSkew(100, time.Hour)
+    network.SetClockSkew(101, -time.Hour)
+
+    var ahead, behind int64
+    ends[0].Call("ClockServer.HandlerNow", 0, &ahead)
+    ends[1].Call("ClockServer.HandlerNow", 0, &behind)
+
+    now := time.Now()
+    if d := time.Unix(0, ahead).Sub(now); d < 59*time.Minute || d > 61*time.Minute {
+        t.Fatalf("expected server 100 to be an hour ahead, got %v", d)
+    }
+    if d := now.Sub(time.Unix(0, behind)); d < 59*time.Minute || d > 61*time.Minute {
+        t.Fatalf("expected server 101 to be an hour behind, got %v", d)
+    }
+
+    if ClockFromContext(context.Background()).Since(now) > time.Minute {
+        t.Fatalf("expected the real clock outside handlers")
+    }
+}
+
+func TestClockDrift(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, _, servers := makeClockNetwork(1)
+    defer network.Cleanup()
+
+    network.SetClockDrift(100, 1.0) // twice as fast
+
+    clock := servers[0].Clock()
+    realStart := time.Now()
+    start := clock.Now()
+    time.Sleep(100 * time.Millisecond)
+    fast := clock.Since(start)
+    real := time.Since(realStart)
+
+    if fast < 2*real-20*time.Millisecond || fast > 2*real+20*time.Millisecond {
+        t.Fatalf("expected clock to advance %v, advanced %v", 2*real, fast)
+    }
+
+    // sleeping on a fast clock takes less real time.
+    realStart = time.Now()
+    clock.Sleep(200 * time.Millisecond)
+    if real := time.Since(realStart); real > 150*time.Millisecond {
+        t.Fatalf("expected clock.Sleep(200ms) to take about 100ms, took %v", real)
+    }
+}
+
+func TestPauseServer(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends, _ := makeClockNetwork(2)
+    defer network.Cleanup()
+
+    network.PauseServer(100, 300*time.Millisecond)
+
+    paused := ends[0].Go("JunkServer.HandlerIntToString", 1, new(string), nil)
+
+    // the other server carries on.
+    start := time.Now()
+    var reply string
+    if !ends[1].Call("JunkServer.HandlerIntToString", 2, &reply) || reply != "2" {
+        t.Fatalf("expected reply to be 2, got %s", reply)
+    }
+    if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+        t.Fatalf("expected unpaused server to answer at once, took %v", elapsed)
+    }
+
+    select {
+    case <-paused.Done:
+        t.Fatalf("expected paused server not to answer yet")
+    case <-time.After(150 * time.Millisecond):
+    }
+
+    call := <-paused.Done
+    if !call.Ok || *call.Reply.(*string) != "1" {
+        t.Fatalf("expected paused server to answer after the pause")
+    }
+    if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+        t.Fatalf("expected the pause to last about 300ms, took %v", elapsed)
+    }
+}
+
+func TestClockDriftLimit(t *testing.T) {
+    network, _, _ := makeClockNetwork(1)
+    defer network.Cleanup()
+
+    for _, drift := range []float64{-1, -2} {
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Fatalf("expected drift %v to panic", drift)
+                }
+            }()
+            network.SetClockDrift(100, drift)
+        }()
+    }
+    network.SetClockDrift(100, -0.5)
+}
+
+func TestPauseRunningHandler(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends, _ := makeClockNetwork(1)
+    defer network.Cleanup()
+
+    var ticks []int64
+    call := ends[0].Go("ClockServer.HandlerTicks", 20, &ticks, nil)
+    time.Sleep(50 * time.Millisecond)
+    network.PauseServer(100, 300*time.Millisecond)
+    <-call.Done
+
+    if !call.Ok || len(ticks) != 20 {
+        t.Fatalf("expected 20 ticks, got %d", len(ticks))
+    }
+    // the handler, already running, made no progress for the pause.
+    longest := time.Duration(0)
+    for i := 1; i < len(ticks); i++ {
+        if gap := time.Duration(ticks[i] - ticks[i-1]); gap > longest {
+            longest = gap
+        }
+    }
+    if longest < 250*time.Millisecond {
+        t.Fatalf("expected the running handler to freeze for about 300ms, longest gap %v", longest)
+    }
+}
@@ -0,0 +1,121 @@
+package labrpc
+
+import (
+    "context"
+    "errors"
+    "runtime"
+    "testing"
+    "time"
+)
+
+type CrashyServer struct {
+    server *Server
+}
+
+func (crashyServer *CrashyServer) HandlerPanic(args int, reply *int) {
+    panic("handler bug")
+}
+
+// a long-running handler that gives up when its server crashes.
+func (crashyServer *CrashyServer) HandlerWait(args int, reply *int) {
+    select {
+    case <-time.After(time.Duration(args) * time.Millisecond):
+        *reply = args
+    case <-crashyServer.server.Context().Done():
+    }
+}
+
+func makeCrashNetwork() (*Network, *ClientEnd, *Server) {
+    network := MakeNetwork()
+    clientEnd := network.MakeEnd("end-42")
+
+    server := MakeServer()
+    server.AddService(MakeService(&CrashyServer{server}))
+    network.AddServer("server-42", server)
+
+    network.Connect("end-42", "server-42")
+    network.Enable("end-42", true)
+
+    return network, clientEnd, server
+}
+
+func TestHandlerPanic(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, clientEnd, server := makeCrashNetwork()
+    defer network.Cleanup()
+
+    var reply int
+    if clientEnd.Call("CrashyServer.HandlerPanic", 42, &reply) {
+        t.Fatalf("expected call of a panicking handler to fail")
+    }
+
+    err := clientEnd.Invoke(context.Background(), "CrashyServer.HandlerPanic", 42, &reply)
+    var handlerError *HandlerError
+    if !errors.As(err, &handlerError) || handlerError.Message != "panic: handler bug" {
+        t.Fatalf("expected the panic to reach the caller, got %v", err)
+    }
+
+    errs := server.GetErrors()
+    if len(errs) != 2 {
+        t.Fatalf("expected 2 recorded errors, got %d", len(errs))
+    }
+    var handlerPanic *HandlerPanic
+    if !errors.As(errs[0], &handlerPanic) ||
+        handlerPanic.ServiceMethod != "CrashyServer.HandlerPanic" || handlerPanic.Value != "handler bug" {
+        t.Fatalf("unexpected recorded error %v", errs[0])
+    }
+
+    // the server keeps working.
+    if !clientEnd.Call("CrashyServer.HandlerWait", 1, &reply) || reply != 1 {
+        t.Fatalf("expected reply to be 1, got %d", reply)
+    }
+}
+
+func TestCrashRestart(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, clientEnd, server := makeCrashNetwork()
+    defer network.Cleanup()
+
+    ctx := server.Context()
+    call := clientEnd.Go("CrashyServer.HandlerWait", 5000, new(int), nil)
+    time.Sleep(50 * time.Millisecond)
+
+    start := time.Now()
+    network.CrashServer("server-42")
+
+    if (<-call.Done).Ok {
+        t.Fatalf("expected in-flight call to fail on crash")
+    }
+    if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+        t.Fatalf("in-flight call took %v to fail after crash", elapsed)
+    }
+    if ctx.Err() == nil {
+        t.Fatalf("expected server context to be cancelled on crash")
+    }
+
+    var reply int
+    if clientEnd.Call("CrashyServer.HandlerWait", 1, &reply) {
+        t.Fatalf("expected call to crashed server to fail")
+    }
+
+    network.RestartServer("server-42", nil)
+    if server.Context().Err() != nil {
+        t.Fatalf("expected fresh server context after restart")
+    }
+    if !clientEnd.Call("CrashyServer.HandlerWait", 1, &reply) || reply != 1 {
+        t.Fatalf("expected reply to be 1 after restart, got %d", reply)
+    }
+
+    // restart with a brand-new server.
+    network.CrashServer("server-42")
+    fresh := MakeServer()
+    fresh.AddService(MakeService(&JunkServer{}))
+    network.RestartServer("server-42", fresh)
+
+    var str string
+    if !clientEnd.Call("JunkServer.HandlerIntToString", 42, &str) || str != "42" {
+        t.Fatalf("expected reply to be 42 from new server, got %s", str)
+    }
+}
@@ -2,11 +2,14 @@ package labrpc
 
 import (
     "bytes"
+    "context"
+    "errors"
     "fmt"
     "lab-rpc/labgob"
     "log"
     "math/rand"
     "reflect"
+    "runtime/debug"
     "strings"
     "sync"
     "sync/atomic"
@@ -14,65 +17,172 @@ import (
 )
 
 type requestMessage struct {
-    endName             interface{} // name of sending ClientEnd
-    serviceMethod       string      // e.g. "Raft.AppendEntries"
+    ctx                 context.Context // caller's context, done when the caller gives up
+    endName             interface{}     // name of sending ClientEnd
+    serviceMethod       string          // e.g. "Raft.AppendEntries"
     argsType            reflect.Type
     args                []byte
-    responseMessageChan chan responseMessage
+    codec               labgob.Codec         // of args, and of the reply
+    strict              bool                 // whether labgob's checks on args and reply are strict
+    responseMessageChan chan responseMessage // buffered, so the network never blocks on a departed caller
 }
 
 type responseMessage struct {
     ok    bool
     reply []byte
+    err   string // from a handler that returned an error or panicked
 }
 
 type ClientEnd struct {
     endName            interface{}         // this end-point's name
     requestMessageChan chan requestMessage // copy of Network.requestMessageChan
     done               chan struct{}       // closed when Network is cleaned up
+    remote             *remoteConn         // non-nil if made by Dial rather than MakeEnd
+    codec              *codecSetting       // copy of Network.codec
+}
+
+func (req *requestMessage) stream() labgob.Stream {
+    return labgob.Stream{Codec: req.codec, Strict: req.strict}
+}
+
+// the codec for args and replies, and whether labgob's checks
+// are strict, shared by a Network and its ClientEnds so that
+// SetCodec() and SetStrict() also reach ends already made.
+type codecSetting struct {
+    mu     sync.Mutex
+    codec  labgob.Codec
+    strict bool
+}
+
+// strict starts out as labgob's process-wide setting.
+func makeCodecSetting(codec labgob.Codec) *codecSetting {
+    return &codecSetting{codec: codec, strict: labgob.Strict()}
+}
+
+func (setting *codecSetting) get() (labgob.Codec, bool) {
+    setting.mu.Lock()
+    defer setting.mu.Unlock()
+    return setting.codec, setting.strict
+}
+
+func (setting *codecSetting) setStrict(strict bool) {
+    setting.mu.Lock()
+    defer setting.mu.Unlock()
+    setting.strict = strict
+}
+
+func (setting *codecSetting) set(codec labgob.Codec) {
+    setting.mu.Lock()
+    defer setting.mu.Unlock()
+    setting.codec = codec
 }
 
 // send an RPC, wait for the reply.
 // the return value indicates success; false means that
 // no reply was received from the server.
 func (clientEnd *ClientEnd) Call(serviceMethod string, args interface{}, reply interface{}) bool {
+    return clientEnd.CallContext(context.Background(), serviceMethod, args, reply)
+}
+
+// like Call, but give up as soon as ctx is cancelled or its
+// deadline passes, in which case false is returned. the network
+// notices the departed caller and stops waiting for the server.
+func (clientEnd *ClientEnd) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) bool {
+    return clientEnd.Invoke(ctx, serviceMethod, args, reply) == nil
+}
+
+// returned by Invoke when no reply was received from the server.
+var ErrNoReply = errors.New("labrpc: no reply")
+
+// returned by Invoke when the handler returned a non-nil error,
+// or panicked. only the message crosses the network, as it would
+// between real processes.
+type HandlerError struct {
+    ServiceMethod string
+    Message       string
+}
+
+func (handlerError *HandlerError) Error() string {
+    return handlerError.ServiceMethod + ": " + handlerError.Message
+}
+
+// like CallContext, but say why the call failed: ctx.Err() if the
+// caller gave up, ErrNoReply if the network or server did not answer,
+// or a *HandlerError if the handler returned an error, in which
+// case reply is left alone. in labgob's strict mode, problems with
+// args are reported as a *labgob.CheckError before anything is
+// sent, and problems with the reply as a *HandlerError from the
+// server's side, or a *labgob.CheckError from the caller's.
+func (clientEnd *ClientEnd) Invoke(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
     req := requestMessage{}
+    req.ctx = ctx
     req.endName = clientEnd.endName
     req.serviceMethod = serviceMethod
     req.argsType = reflect.TypeOf(args)
-    req.responseMessageChan = make(chan responseMessage)
-
-    queryBuffer := new(bytes.Buffer)
-    queryEncoder := labgob.NewEncoder(queryBuffer)
-    if err := queryEncoder.Encode(args); err != nil {
+    req.codec, req.strict = clientEnd.codec.get()
+    req.responseMessageChan = make(chan responseMessage, 1)
+
+    // large []byte fields, such as a snapshot, are streamed rather
+    // than copied through the codec; see labgob.EncodeStream().
+    queryBuffer := labgob.GetBuffer()
+    if err := req.stream().Encode(queryBuffer, args); err != nil {
+        labgob.PutBuffer(queryBuffer)
+        var checkError *labgob.CheckError
+        if errors.As(err, &checkError) {
+            // strict mode found a problem with args.
+            return err
+        }
         panic(err)
     }
-    req.args = queryBuffer.Bytes()
+    req.args = labgob.TakeBytes(queryBuffer)
 
     //
     // send the request.
     //
-    select {
-    case clientEnd.requestMessageChan <- req:
-        // the request has been sent.
-    case <-clientEnd.done:
-        // entire Network has been destroyed.
-        return false
+    if clientEnd.remote != nil {
+        if !clientEnd.remote.send(req) {
+            // the connection to the server is broken.
+            return ErrNoReply
+        }
+    } else {
+        select {
+        case clientEnd.requestMessageChan <- req:
+            // the request has been sent.
+        case <-clientEnd.done:
+            // entire Network has been destroyed.
+            return ErrNoReply
+        case <-ctx.Done():
+            // the caller gave up before the network took the request.
+            return ctx.Err()
+        }
     }
 
     //
     // wait for the reply.
     //
-    res := <-req.responseMessageChan
-    if res.ok {
-        replyBuffer := bytes.NewBuffer(res.reply)
-        replyEncoder := labgob.NewDecoder(replyBuffer)
-        if err := replyEncoder.Decode(reply); err != nil {
+    var res responseMessage
+    select {
+    case res = <-req.responseMessageChan:
+    case <-ctx.Done():
+        // the caller gave up; the network will still deliver
+        // into the buffered channel, which is then garbage.
+        return ctx.Err()
+    }
+
+    if res.err != "" {
+        return &HandlerError{serviceMethod, res.err}
+    } else if res.ok {
+        if err := req.stream().Decode(bytes.NewReader(res.reply), reply); err != nil {
+            var checkError *labgob.CheckError
+            if errors.As(err, &checkError) {
+                // strict mode found a problem with reply.
+                return err
+            }
             log.Fatalf("ClientEnd.Call(): decode reply: %v\n", err)
         }
-        return true
+        return nil
     } else {
-        return false
+        return ErrNoReply
     }
 }
 
@@ -84,11 +194,22 @@ type Network struct {
     ends               map[interface{}]*ClientEnd  // endName -> ClientEnd
     enabled            map[interface{}]bool        // endName -> enabled
     servers            map[interface{}]*Server     // serverName -> Server
+    crashed            map[interface{}]*Server     // serverName -> Server, for RestartServer()
+    persisters         map[interface{}]*Persister  // serverName -> Persister
     connections        map[interface{}]interface{} // endName -> serverName
     requestMessageChan chan requestMessage
-    done               chan struct{} // closed when Network is cleaned up
-    count              int32         // total RPC count, for statistics
-    bytes              int64         // total bytes send, for statistics
+    done               chan struct{}             // closed when Network is cleaned up
+    count              int32                     // total RPC count, for statistics
+    bytes              int64                     // total bytes send, for statistics
+    stats              *netStats                 // per-method/server/end breakdown
+    bandwidth          int64                     // bytes per second on each link, 0 for unlimited
+    linkFree           map[interface{}]time.Time // endName -> when the link finishes sending what it has
+    maxMessageSize     int                       // largest request or reply, 0 for unlimited
+    byteBudget         int64                     // total bytes before budgetExceeded is called, 0 for unlimited
+    budgetExceeded     func(total int64)
+    overBudget         int64         // total when the budget was first exceeded, 0 if not yet
+    tracer             *tracer       // optional record of every request
+    codec              *codecSetting // for args and replies, shared with every ClientEnd
 }
 
 func MakeNetwork() *Network {
@@ -97,9 +218,15 @@ func MakeNetwork() *Network {
     network.ends = map[interface{}]*ClientEnd{}
     network.enabled = map[interface{}]bool{}
     network.servers = map[interface{}]*Server{}
+    network.crashed = map[interface{}]*Server{}
+    network.persisters = map[interface{}]*Persister{}
+    network.linkFree = map[interface{}]time.Time{}
     network.connections = map[interface{}](interface{}){}
     network.requestMessageChan = make(chan requestMessage)
     network.done = make(chan struct{})
+    network.stats = makeNetStats()
+    network.tracer = &tracer{}
+    network.codec = makeCodecSetting(labgob.Gob)
 
     // single goroutine to handle all ClientEnd.Call()s
     go func() {
@@ -107,7 +234,7 @@ func MakeNetwork() *Network {
             select {
             case xreq := <-network.requestMessageChan:
                 atomic.AddInt32(&network.count, 1)
-                atomic.AddInt64(&network.bytes, int64(len(xreq.args)))
+                network.addBytes(len(xreq.args))
                 go network.processRequest(xreq)
             case <-network.done:
                 return
@@ -143,6 +270,18 @@ func (network *Network) LongDelays(yes bool) {
     network.longDelays = yes
 }
 
+// encode args and replies with codec rather than gob, from the
+// next call on. a call already in flight keeps its codec.
+func (network *Network) SetCodec(codec labgob.Codec) {
+    network.codec.set(codec)
+}
+
+// make labgob's checks on args and replies strict, or not,
+// from the next call on, whatever labgob.SetStrict() says.
+func (network *Network) SetStrict(strict bool) {
+    network.codec.setStrict(strict)
+}
+
 func (network *Network) readEndNameInfo(endName interface{}) (
     enabled bool, serverName interface{}, server *Server, reliable bool, longreordering bool,
 ) {
@@ -170,18 +309,27 @@ func (network *Network) isServerDead(endName interface{}, serverName interface{}
 }
 
 func (network *Network) processRequest(req requestMessage) {
+    start := time.Now()
     enabled, serverName, server, reliable, longreordering := network.readEndNameInfo(req.endName)
 
     if enabled && serverName != nil && server != nil {
+        if network.tooLarge(len(req.args)) {
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, FateTooLarge, 0)
+            return
+        }
+
         if !reliable {
             // short delay
             ms := (rand.Int() % 27)
             time.Sleep(time.Duration(ms) * time.Millisecond)
         }
 
+        // the request takes a while to squeeze through the link.
+        time.Sleep(network.transmitDelay(req.endName, len(req.args)))
+
         if !reliable && (rand.Int()%1000) < 100 {
             // drop the request, return as if timeout
-            req.responseMessageChan <- responseMessage{false, nil}
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, FateDroppedRequest, 0)
             return
         }
 
@@ -189,6 +337,7 @@ func (network *Network) processRequest(req requestMessage) {
         // in a separate thread so that we can periodically check
         // if the server has been killed and the RPC should get a
         // failure reply.
+        serverCtx := server.Context()
         responseMessageChan := make(chan responseMessage)
         go func() {
             r := server.dispatch(req)
@@ -197,38 +346,53 @@ func (network *Network) processRequest(req requestMessage) {
 
         // wait for handler to return,
         // but stop waiting if DeleteServer() has been called,
-        // and return an error.
+        // or if the caller has gone away, and return an error.
         var reply responseMessage
         replyOK := false
         serverDead := false
-        for !replyOK && !serverDead {
+        callerGone := false
+        for !replyOK && !serverDead && !callerGone {
             select {
             case reply = <-responseMessageChan:
                 replyOK = true
+            case <-req.ctx.Done():
+                callerGone = true
+            case <-serverCtx.Done():
+                // CrashServer() has been called.
+                serverDead = true
             case <-time.After(100 * time.Millisecond):
                 serverDead = network.isServerDead(req.endName, serverName, server)
-                if serverDead {
-                    go func() {
-                        <-responseMessageChan // drain channel to let the goroutine created earlier terminate
-                    }()
-                }
             }
         }
+        if !replyOK {
+            go func() {
+                <-responseMessageChan // drain channel to let the goroutine created earlier terminate
+            }()
+        }
 
         // do not reply if DeleteServer() has been called, i.e.
         // the server has been killed. this is needed to avoid
         // situation in which a client gets a positive reply
         // to an Append, but the server persisted the update
-        // into the old Persister. config.go is careful to call
-        // DeleteServer() before superseding the Persister.
-        serverDead = network.isServerDead(req.endName, serverName, server)
+        // into the old Persister. DeleteServer() and
+        // CrashServer() supersede the Persister themselves.
+        // likewise if CrashServer() has been called, even if
+        // the same Server has since been restarted.
+        serverDead = network.isServerDead(req.endName, serverName, server) || serverCtx.Err() != nil
 
         if !replyOK || serverDead {
-            // server was killed while we were waiting; return error.
-            req.responseMessageChan <- responseMessage{false, nil}
+            // server was killed while we were waiting,
+            // or the caller gave up; return error.
+            fate := FateServerDead
+            if callerGone && !serverDead {
+                fate = FateCallerGone
+            }
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, fate, 0)
+        } else if network.tooLarge(len(reply.reply)) {
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, FateTooLarge, 0)
         } else if !reliable && (rand.Int()%1000) < 100 {
             // drop the reply, return as if timeout
-            req.responseMessageChan <- responseMessage{false, nil}
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, FateDroppedReply, 0)
         } else if longreordering && rand.Intn(900) < 600 {
             // delay the response for a while
             ms := 200 + rand.Intn(1+rand.Intn(2000))
@@ -236,12 +400,12 @@ func (network *Network) processRequest(req requestMessage) {
             // the number of goroutines, so that the race
             // detector is less likely to get upset.
             time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
-                atomic.AddInt64(&network.bytes, int64(len(reply.reply)))
-                req.responseMessageChan <- reply
+                network.addBytes(len(reply.reply))
+                network.deliver(req, serverName, start, reply, FateDelayed, time.Duration(ms)*time.Millisecond)
             })
         } else {
-            atomic.AddInt64(&network.bytes, int64(len(reply.reply)))
-            req.responseMessageChan <- reply
+            network.addBytes(len(reply.reply))
+            network.deliver(req, serverName, start, reply, FateDelivered, 0)
         }
     } else {
         // simulate no reply and eventual timeout.
@@ -256,11 +420,24 @@ func (network *Network) processRequest(req requestMessage) {
             ms = (rand.Int() % 100)
         }
         time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
-            req.responseMessageChan <- responseMessage{false, nil}
+            network.deliver(req, serverName, start, responseMessage{false, nil, ""}, FateUnreachable, time.Duration(ms)*time.Millisecond)
         })
     }
 }
 
+// hand the outcome of a request back to the waiting ClientEnd,
+// and account for it in the per-method/server/end statistics
+// and, if enabled, the trace. delay is how long the network
+// deliberately held the outcome back.
+func (network *Network) deliver(
+    req requestMessage, serverName interface{}, start time.Time,
+    res responseMessage, fate Fate, delay time.Duration,
+) {
+    network.stats.record(req.endName, serverName, req.serviceMethod, len(req.args), len(res.reply), res.ok, time.Since(start))
+    network.tracer.record(req, serverName, start, res, fate, delay)
+    req.responseMessageChan <- res
+}
+
 // create a client end-point.
 // start the thread that listens and delivers.
 func (network *Network) MakeEnd(endName interface{}) *ClientEnd {
@@ -275,6 +452,7 @@ func (network *Network) MakeEnd(endName interface{}) *ClientEnd {
     clientEnd.endName = endName
     clientEnd.requestMessageChan = network.requestMessageChan
     clientEnd.done = network.done
+    clientEnd.codec = network.codec
     network.ends[endName] = clientEnd
     network.enabled[endName] = false
     network.connections[endName] = nil
@@ -289,11 +467,61 @@ func (network *Network) AddServer(serverName interface{}, server *Server) {
     network.servers[serverName] = server
 }
 
+// remove a server from the network. if it has a Persister, the
+// Persister is superseded by a copy, so that the deleted server
+// can keep writing to the old one without affecting its successor.
 func (network *Network) DeleteServer(serverName interface{}) {
     network.mu.Lock()
     defer network.mu.Unlock()
 
     network.servers[serverName] = nil
+    if persister := network.persisters[serverName]; persister != nil {
+        network.persisters[serverName] = persister.Copy()
+    }
+}
+
+// simulate a crash of a server. unlike DeleteServer(), delivery
+// stops immediately: RPCs waiting on the server's handlers fail
+// at once rather than at the next periodic check, no further
+// handlers are started, and the server's Context() is cancelled
+// so that handlers still running can notice and give up.
+// if the server has a Persister, it is superseded by what
+// survives the crash, see Persister.Crash().
+func (network *Network) CrashServer(serverName interface{}) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    server := network.servers[serverName]
+    if server == nil {
+        return
+    }
+    network.servers[serverName] = nil
+    network.crashed[serverName] = server
+    server.crash()
+
+    if persister := network.persisters[serverName]; persister != nil {
+        network.persisters[serverName] = persister.Crash()
+    }
+}
+
+// bring a crashed server back. if server is nil, the crashed
+// Server is restarted with a fresh Context(); otherwise server,
+// typically built from scratch the way a rebooted process would,
+// takes its place. either way, GetPersister() now returns the
+// fresh copy made at the crash.
+func (network *Network) RestartServer(serverName interface{}, server *Server) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    if server == nil {
+        server = network.crashed[serverName]
+        if server == nil {
+            log.Fatalf("RestartServer: %v has not crashed\n", serverName)
+        }
+        server.restart()
+    }
+    delete(network.crashed, serverName)
+    network.servers[serverName] = server
 }
 
 // connect a ClientEnd to a server.
@@ -336,17 +564,50 @@ func (network *Network) GetTotalBytes() int64 {
 // the same rpc dispatcher. so that e.g. both a Raft
 // and a k/v server can listen to the same rpc endpoint.
 type Server struct {
-    mu       sync.Mutex
-    services map[string]*Service
-    count    int // incoming RPCs
+    mu          sync.Mutex
+    services    map[string]*Service
+    count       int                // incoming RPCs
+    errors      []error            // handler panics
+    ctx         context.Context    // cancelled when the server crashes
+    cancel      context.CancelFunc // cancels ctx
+    clock       *Clock             // this server's view of the time
+    pausedUntil time.Time          // handlers are frozen until then
 }
 
 func MakeServer() *Server {
     server := &Server{}
     server.services = map[string]*Service{}
+    server.clock = makeClock()
+    server.clock.paused = server.waitWhilePaused
+    server.ctx, server.cancel = server.makeContext()
     return server
 }
 
+// a fresh lifecycle context, which also carries the server's Clock.
+func (server *Server) makeContext() (context.Context, context.CancelFunc) {
+    return context.WithCancel(context.WithValue(context.Background(), clockKey{}, server.clock))
+}
+
+func (server *Server) crash() {
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    server.cancel()
+}
+
+func (server *Server) restart() {
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    server.ctx, server.cancel = server.makeContext()
+}
+
+// a context that is cancelled when Network.CrashServer() is called,
+// for long-running handlers to watch.
+func (server *Server) Context() context.Context {
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    return server.ctx
+}
+
 func (server *Server) AddService(svc *Service) {
     server.mu.Lock()
     defer server.mu.Unlock()
@@ -356,6 +617,12 @@ func (server *Server) AddService(svc *Service) {
 func (server *Server) dispatch(req requestMessage) responseMessage {
     server.mu.Lock()
 
+    if server.ctx.Err() != nil {
+        // crashed servers run no handlers.
+        server.mu.Unlock()
+        return responseMessage{false, nil, ""}
+    }
+
     server.count += 1
 
     // split Raft.AppendEntries into service and method
@@ -368,7 +635,11 @@ func (server *Server) dispatch(req requestMessage) responseMessage {
     server.mu.Unlock()
 
     if ok {
-        return service.dispatch(methodName, req)
+        // a paused server neither starts handlers nor sends replies.
+        server.waitWhilePaused()
+        res := server.callService(service, methodName, req)
+        server.waitWhilePaused()
+        return res
     } else {
         choices := []string{}
         for k := range server.services {
@@ -378,23 +649,129 @@ func (server *Server) dispatch(req requestMessage) responseMessage {
             "labrpc.Server.dispatch(): unknown service %v in %v.%v; expecting one of %v\n",
             serviceName, serviceName, methodName, choices,
         )
-        return responseMessage{false, nil}
+        return responseMessage{false, nil, ""}
     }
 }
 
+// call the handler, turning a panic into a failed reply rather
+// than letting it take down the whole test process.
+func (server *Server) callService(service *Service, methodName string, req requestMessage) (res responseMessage) {
+    defer func() {
+        if r := recover(); r != nil {
+            err := &HandlerPanic{req.serviceMethod, r, debug.Stack()}
+            server.mu.Lock()
+            server.errors = append(server.errors, err)
+            server.mu.Unlock()
+            res = responseMessage{false, nil, fmt.Sprintf("panic: %v", r)}
+        }
+    }()
+    return service.dispatch(server.Context(), methodName, req)
+}
+
+// a context for a handler, done when either the caller's
+// context or the server's lifecycle context is done, and
+// carrying the server's Clock.
+func handlerContext(callerCtx context.Context, serverCtx context.Context) (context.Context, context.CancelFunc) {
+    if clock := serverCtx.Value(clockKey{}); clock != nil {
+        callerCtx = context.WithValue(callerCtx, clockKey{}, clock)
+    }
+    ctx, cancel := context.WithCancel(callerCtx)
+    go func() {
+        select {
+        case <-serverCtx.Done():
+            cancel()
+        case <-ctx.Done():
+        }
+    }()
+    return ctx, cancel
+}
+
 func (server *Server) GetCount() int {
     server.mu.Lock()
     defer server.mu.Unlock()
     return server.count
 }
 
+// the panics recovered from this server's handlers, oldest first.
+func (server *Server) GetErrors() []error {
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    return append([]error{}, server.errors...)
+}
+
+// the error recorded when a handler panics.
+type HandlerPanic struct {
+    ServiceMethod string
+    Value         interface{} // as returned by recover()
+    Stack         []byte
+}
+
+func (handlerPanic *HandlerPanic) Error() string {
+    return fmt.Sprintf("labrpc: handler %v panicked: %v", handlerPanic.ServiceMethod, handlerPanic.Value)
+}
+
 // an object with methods that can be called via RPC.
 // a single server may have more than one Service.
 type Service struct {
     name     string
     receiver reflect.Value
     typ      reflect.Type
-    methods  map[string]reflect.Method
+    methods  map[string]*handlerMethod
+}
+
+// a method of a Service, in one of the shapes
+//
+//    func (rcvr) Handler(args T, reply *R)
+//    func (rcvr) Handler(args T, reply *R) error
+//    func (rcvr) Handler(ctx context.Context, args T, reply *R)
+//    func (rcvr) Handler(ctx context.Context, args T, reply *R) error
+//
+// ctx carries the caller's deadline and is cancelled when the
+// caller gives up or the server crashes. a non-nil error is
+// passed back to the caller instead of the reply.
+type handlerMethod struct {
+    method      reflect.Method
+    withContext bool
+    withError   bool
+    argsType    reflect.Type
+    replyType   reflect.Type // what the reply argument points to
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func makeHandlerMethod(method reflect.Method) (*handlerMethod, bool) {
+    methodType := method.Type
+    if method.PkgPath != "" {
+        return nil, false
+    }
+
+    handler := &handlerMethod{}
+    handler.method = method
+
+    first := 1 // skip the receiver
+    switch {
+    case methodType.NumIn() == 4 && methodType.In(1) == contextType:
+        handler.withContext = true
+        first = 2
+    case methodType.NumIn() != 3:
+        return nil, false
+    }
+
+    switch {
+    case methodType.NumOut() == 1 && methodType.Out(0) == errorType:
+        handler.withError = true
+    case methodType.NumOut() != 0:
+        return nil, false
+    }
+
+    handler.argsType = methodType.In(first)
+    if methodType.In(first+1).Kind() != reflect.Ptr {
+        return nil, false
+    }
+    handler.replyType = methodType.In(first + 1).Elem()
+
+    return handler, true
 }
 
 //    type JunkServer struct {
@@ -407,48 +784,66 @@ func MakeService(receiver interface{}) *Service {
     service.typ = reflect.TypeOf(receiver) // labrpc.JunkServer
     service.receiver = reflect.ValueOf(receiver)
     service.name = reflect.Indirect(service.receiver).Type().Name() // JunkServer
-    service.methods = map[string]reflect.Method{}
+    service.methods = map[string]*handlerMethod{}
 
     for i := 0; i < service.typ.NumMethod(); i++ {
         method := service.typ.Method(i)
-        methodType := method.Type
         methodName := method.Name
 
-        if method.PkgPath != "" ||
-            methodType.NumIn() != 3 ||
-            methodType.In(2).Kind() != reflect.Ptr ||
-            methodType.NumOut() != 0 {
+        if handler, ok := makeHandlerMethod(method); !ok {
             // the method is not suitable for a handler
             fmt.Printf("bad method: %v\n", methodName)
         } else {
             // the method looks like a handler
-            service.methods[methodName] = method
+            service.methods[methodName] = handler
         }
     }
 
     return service
 }
 
-func (service *Service) dispatch(methodName string, req requestMessage) responseMessage {
-    if method, ok := service.methods[methodName]; ok {
-        args := reflect.New(req.argsType)
+// serverCtx is cancelled when the server crashes.
+func (service *Service) dispatch(serverCtx context.Context, methodName string, req requestMessage) responseMessage {
+    if handler, ok := service.methods[methodName]; ok {
+        // requests from a remote ClientEnd do not carry the
+        // caller's type; the handler's own argument type is used.
+        argsType := req.argsType
+        if argsType == nil {
+            argsType = handler.argsType
+        }
+        args := reflect.New(argsType)
+
+        if err := req.stream().Decode(bytes.NewReader(req.args), args.Interface()); err != nil {
+            // e.g. strict mode found a problem, or a remote
+            // client sent something corrupt.
+            return responseMessage{true, nil, err.Error()}
+        }
 
-        argsBuffer := bytes.NewBuffer(req.args)
-        argsDecoder := labgob.NewDecoder(argsBuffer)
-        argsDecoder.Decode(args.Interface())
+        reply := reflect.New(handler.replyType)
 
-        replyType := method.Type.In(2)
-        replyType = replyType.Elem()
-        reply := reflect.New(replyType)
+        in := []reflect.Value{service.receiver}
+        if handler.withContext {
+            ctx, cancel := handlerContext(req.ctx, serverCtx)
+            defer cancel()
+            in = append(in, reflect.ValueOf(ctx))
+        }
+        in = append(in, args.Elem(), reply)
 
-        function := method.Func
-        function.Call([]reflect.Value{service.receiver, args.Elem(), reply})
+        function := handler.method.Func
+        out := function.Call(in)
 
-        replyBuffer := new(bytes.Buffer)
-        replyEncoder := labgob.NewEncoder(replyBuffer)
-        replyEncoder.EncodeValue(reply)
+        if handler.withError && !out[0].IsNil() {
+            return responseMessage{true, nil, out[0].Interface().(error).Error()}
+        }
+
+        replyBuffer := labgob.GetBuffer()
+        if err := req.stream().Encode(replyBuffer, reply.Interface()); err != nil {
+            // e.g. strict mode found a lower-case field.
+            labgob.PutBuffer(replyBuffer)
+            return responseMessage{true, nil, err.Error()}
+        }
 
-        return responseMessage{true, replyBuffer.Bytes()}
+        return responseMessage{true, labgob.TakeBytes(replyBuffer), ""}
     } else {
         choices := []string{}
         for k := range service.methods {
@@ -458,6 +853,6 @@ func (service *Service) dispatch(methodName string, req requestMessage) response
             "labrpc.Service.dispatch(): unknown method %v in %v; expecting one of %v\n",
             methodName, req.serviceMethod, choices,
         )
-        return responseMessage{false, nil}
+        return responseMessage{false, nil, ""}
     }
 }
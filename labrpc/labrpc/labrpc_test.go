@@ -1,10 +1,17 @@
 package labrpc
 
 import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "lab-rpc/labgob"
+    "net"
     "runtime"
     "strconv"
     "sync"
     "testing"
+    "time"
 )
 
 type JunkArgs struct {
@@ -37,6 +44,11 @@ func (junkServer *JunkServer) HandlerIntToString(args int, reply *string) {
     *reply = strconv.Itoa(args)
 }
 
+func (junkServer *JunkServer) HandlerSleep(args int, reply *int) {
+    time.Sleep(time.Duration(args) * time.Millisecond)
+    *reply = args
+}
+
 func (junkServer *JunkServer) HandlerWithPointer(args *JunkArgs, reply *JunksReply) {
     reply.X = "pointer"
 }
@@ -119,3 +131,415 @@ func TestTypes(t *testing.T) {
         }
     }
 }
+
+func TestCodec(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    for _, codec := range []labgob.Codec{labgob.Gob, labgob.JSON, labgob.MsgPack} {
+        // reaches the ClientEnd already made.
+        network.SetCodec(codec)
+
+        {
+            var reply string
+            if !ends[0].Call("JunkServer.HandlerIntToString", 42, &reply) || reply != "42" {
+                t.Fatalf("%v: expected reply to be 42, got %s", codec.Name(), reply)
+            }
+        }
+
+        {
+            var reply JunksReply
+            if !ends[0].Call("JunkServer.HandlerWithPointer", &JunkArgs{}, &reply) || reply.X != "pointer" {
+                t.Fatalf("%v: expected reply to be pointer, got %s", codec.Name(), reply.X)
+            }
+        }
+    }
+}
+
+func TestStrictReply(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+    network.SetStrict(true)
+
+    // a reply variable re-used from an earlier call.
+    reply := "stale"
+    err := ends[0].Invoke(context.Background(), "JunkServer.HandlerIntToString", 42, &reply)
+    var checkError *labgob.CheckError
+    if !errors.As(err, &checkError) || checkError.Problem != labgob.NonDefaultValue {
+        t.Fatalf("expected a non-default value error, got %v", err)
+    }
+}
+
+type LowerCaseArgs struct {
+    X      int
+    hidden int
+}
+
+func TestStrictArgs(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+    network.SetStrict(true)
+
+    // reported to the caller, rather than a panic.
+    var reply string
+    err := ends[0].Invoke(context.Background(), "JunkServer.HandlerIntToString", LowerCaseArgs{}, &reply)
+    var checkError *labgob.CheckError
+    if !errors.As(err, &checkError) || checkError.Problem != labgob.LowerCaseField {
+        t.Fatalf("expected a lower-case field error, got %v", err)
+    }
+
+    // strictness belongs to the network, not the process.
+    if labgob.Strict() {
+        t.Fatalf("expected labgob's own setting to be left alone")
+    }
+}
+
+func TestCorruptArgs(t *testing.T) {
+    server := MakeServer()
+    server.AddService(MakeService(&JunkServer{}))
+
+    req := requestMessage{}
+    req.ctx = context.Background()
+    req.serviceMethod = "JunkServer.HandlerIntToString"
+    req.args = []byte("not gob at all")
+    req.codec = labgob.Gob
+    if res := server.dispatch(req); res.err == "" {
+        t.Fatalf("expected corrupt args to fail rather than reach the handler")
+    }
+}
+
+func TestCallContextDeadline(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    network.LongDelays(true)
+
+    clientEnd := network.MakeEnd("end-42")
+
+    server := MakeServer()
+    server.AddService(MakeService(&JunkServer{}))
+    network.AddServer("server-42", server)
+
+    network.Connect("end-42", "server-42")
+    // the end stays disabled, so the network would delay up to 7 seconds.
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+
+    start := time.Now()
+    var reply string
+    if clientEnd.CallContext(ctx, "JunkServer.HandlerIntToString", 42, &reply) {
+        t.Fatalf("expected CallContext to fail on a disabled end")
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Fatalf("CallContext took %v, expected it to return at the deadline", elapsed)
+    }
+}
+
+func TestCallContextCancel(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    clientEnd := network.MakeEnd("end-42")
+
+    server := MakeServer()
+    server.AddService(MakeService(&JunkServer{}))
+    network.AddServer("server-42", server)
+
+    network.Connect("end-42", "server-42")
+    network.Enable("end-42", true)
+
+    before := runtime.NumGoroutine()
+
+    for i := 0; i < 10; i++ {
+        ctx, cancel := context.WithCancel(context.Background())
+        time.AfterFunc(20*time.Millisecond, cancel)
+
+        var reply int
+        if clientEnd.CallContext(ctx, "JunkServer.HandlerSleep", 300, &reply) {
+            t.Fatalf("expected CallContext to fail after cancel")
+        }
+    }
+
+    // every handler has finished by now, and the network must
+    // not be left with goroutines waiting on departed callers.
+    time.Sleep(500 * time.Millisecond)
+    if after := runtime.NumGoroutine(); after > before {
+        t.Fatalf("goroutines leaked: %d before, %d after", before, after)
+    }
+
+    {
+        var reply int
+        if !clientEnd.CallContext(context.Background(), "JunkServer.HandlerSleep", 1, &reply) || reply != 1 {
+            t.Fatalf("expected reply to be 1, got %d", reply)
+        }
+    }
+}
+
+type ContextServer struct {
+    cancelled chan error
+}
+
+func (contextServer *ContextServer) HandlerError(args int, reply *int) error {
+    if args < 0 {
+        return fmt.Errorf("negative %d", args)
+    }
+    *reply = args
+    return nil
+}
+
+func (contextServer *ContextServer) HandlerDeadline(ctx context.Context, args int, reply *bool) {
+    _, *reply = ctx.Deadline()
+}
+
+func (contextServer *ContextServer) HandlerWait(ctx context.Context, args int, reply *int) error {
+    select {
+    case <-time.After(time.Duration(args) * time.Millisecond):
+        *reply = args
+        return nil
+    case <-ctx.Done():
+        contextServer.cancelled <- ctx.Err()
+        return ctx.Err()
+    }
+}
+
+func (contextServer *ContextServer) NotAHandler(args int) {
+}
+
+func makeContextNetwork() (*Network, *ClientEnd, *ContextServer) {
+    network := MakeNetwork()
+    clientEnd := network.MakeEnd("end-42")
+
+    contextServer := &ContextServer{make(chan error, 10)}
+    server := MakeServer()
+    server.AddService(MakeService(contextServer))
+    network.AddServer("server-42", server)
+
+    network.Connect("end-42", "server-42")
+    network.Enable("end-42", true)
+
+    return network, clientEnd, contextServer
+}
+
+func TestHandlerShapes(t *testing.T) {
+    service := MakeService(&ContextServer{})
+    for _, name := range []string{"HandlerError", "HandlerDeadline", "HandlerWait"} {
+        if _, ok := service.methods[name]; !ok {
+            t.Fatalf("expected %v to be accepted as a handler", name)
+        }
+    }
+    if _, ok := service.methods["NotAHandler"]; ok {
+        t.Fatalf("expected NotAHandler to be rejected")
+    }
+}
+
+func TestHandlerError(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, clientEnd, _ := makeContextNetwork()
+    defer network.Cleanup()
+
+    var reply int
+    if err := clientEnd.Invoke(context.Background(), "ContextServer.HandlerError", 42, &reply); err != nil || reply != 42 {
+        t.Fatalf("expected reply to be 42, got %d, %v", reply, err)
+    }
+
+    reply = 0
+    err := clientEnd.Invoke(context.Background(), "ContextServer.HandlerError", -1, &reply)
+    var handlerError *HandlerError
+    if !errors.As(err, &handlerError) || handlerError.Message != "negative -1" {
+        t.Fatalf("expected handler error, got %v", err)
+    }
+    if reply != 0 {
+        t.Fatalf("expected reply to be left alone on error, got %d", reply)
+    }
+    if clientEnd.Call("ContextServer.HandlerError", -1, &reply) {
+        t.Fatalf("expected Call to fail on handler error")
+    }
+
+    network.Enable("end-42", false)
+    if err := clientEnd.Invoke(context.Background(), "ContextServer.HandlerError", 1, &reply); err != ErrNoReply {
+        t.Fatalf("expected ErrNoReply on a disabled end, got %v", err)
+    }
+}
+
+func TestHandlerContext(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, clientEnd, contextServer := makeContextNetwork()
+    defer network.Cleanup()
+
+    {
+        var hasDeadline bool
+        ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+        defer cancel()
+        if !clientEnd.CallContext(ctx, "ContextServer.HandlerDeadline", 0, &hasDeadline) || !hasDeadline {
+            t.Fatalf("expected handler to see the caller's deadline")
+        }
+        if !clientEnd.Call("ContextServer.HandlerDeadline", 0, &hasDeadline) || hasDeadline {
+            t.Fatalf("expected handler to see no deadline")
+        }
+    }
+
+    {
+        // the caller gives up.
+        ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+        defer cancel()
+        var reply int
+        if err := clientEnd.Invoke(ctx, "ContextServer.HandlerWait", 5000, &reply); err != context.DeadlineExceeded {
+            t.Fatalf("expected DeadlineExceeded, got %v", err)
+        }
+        select {
+        case <-contextServer.cancelled:
+        case <-time.After(time.Second):
+            t.Fatalf("expected handler context to be cancelled with the caller's")
+        }
+    }
+
+    {
+        // the server crashes.
+        call := clientEnd.Go("ContextServer.HandlerWait", 5000, new(int), nil)
+        time.Sleep(20 * time.Millisecond)
+        network.CrashServer("server-42")
+        if (<-call.Done).Ok {
+            t.Fatalf("expected call to fail on crash")
+        }
+        select {
+        case <-contextServer.cancelled:
+        case <-time.After(time.Second):
+            t.Fatalf("expected handler context to be cancelled by the crash")
+        }
+    }
+}
+
+func TestHandlerContextTCP(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("listen: %v", err)
+    }
+    defer listener.Close()
+
+    contextServer := &ContextServer{make(chan error, 10)}
+    server := MakeServer()
+    server.AddService(MakeService(contextServer))
+    go server.Serve(listener)
+
+    clientEnd, err := Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    defer clientEnd.Close()
+
+    var reply int
+    err = clientEnd.Invoke(context.Background(), "ContextServer.HandlerError", -1, &reply)
+    var handlerError *HandlerError
+    if !errors.As(err, &handlerError) || handlerError.Message != "negative -1" {
+        t.Fatalf("expected handler error over TCP, got %v", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    var hasDeadline bool
+    if !clientEnd.CallContext(ctx, "ContextServer.HandlerDeadline", 0, &hasDeadline) || !hasDeadline {
+        t.Fatalf("expected handler to see the caller's deadline over TCP")
+    }
+}
+
+type SnapshotArgs struct {
+    Term              int
+    LastIncludedIndex int
+    Data              []byte
+}
+
+type SnapshotReply struct {
+    Term int
+    Size int
+}
+
+type SnapshotServer struct{}
+
+func (snapshotServer *SnapshotServer) InstallSnapshot(args SnapshotArgs, reply *SnapshotReply) {
+    reply.Term = args.Term
+    reply.Size = len(args.Data)
+}
+
+func (snapshotServer *SnapshotServer) Echo(args SnapshotArgs, reply *SnapshotArgs) {
+    *reply = args
+}
+
+func TestLargeArgs(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    server := MakeServer()
+    server.AddService(MakeService(&SnapshotServer{}))
+    network.AddServer("server", server)
+    clientEnd := network.MakeEnd("end")
+    network.Connect("end", "server")
+    network.Enable("end", true)
+
+    data := make([]byte, 1<<20+3)
+    for i := range data {
+        data[i] = byte(i * 7)
+    }
+
+    for _, codec := range []labgob.Codec{labgob.Gob, labgob.JSON, labgob.MsgPack} {
+        network.SetCodec(codec)
+        args := SnapshotArgs{Term: 3, LastIncludedIndex: 99, Data: data}
+        var reply SnapshotArgs
+        if !clientEnd.Call("SnapshotServer.Echo", args, &reply) {
+            t.Fatalf("%v: expected the call to succeed", codec.Name())
+        }
+        if reply.Term != 3 || reply.LastIncludedIndex != 99 || !bytes.Equal(reply.Data, data) {
+            t.Fatalf("%v: expected the snapshot back, got %d bytes", codec.Name(), len(reply.Data))
+        }
+    }
+}
+
+func BenchmarkInstallSnapshot(b *testing.B) {
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    server := MakeServer()
+    server.AddService(MakeService(&SnapshotServer{}))
+    network.AddServer("server", server)
+    clientEnd := network.MakeEnd("end")
+    network.Connect("end", "server")
+    network.Enable("end", true)
+
+    args := SnapshotArgs{Term: 1, LastIncludedIndex: 1000, Data: make([]byte, 4<<20)}
+    b.SetBytes(int64(len(args.Data)))
+    b.ReportAllocs()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        var reply SnapshotReply
+        if !clientEnd.Call("SnapshotServer.InstallSnapshot", args, &reply) || reply.Size != len(args.Data) {
+            b.Fatalf("expected the snapshot to arrive, got %+v", reply)
+        }
+    }
+}
+
+func BenchmarkCall(b *testing.B) {
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", i, &reply)
+    }
+}
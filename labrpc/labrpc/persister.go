@@ -0,0 +1,167 @@
+package labrpc
+
+//
+// support for Raft and kv server to save persistent
+// Raft state (log &c) and k/v server snapshots.
+//
+// a Network can hold a Persister for each server name, and
+// replaces it with a copy when the server is deleted or crashes,
+// so that writes by the old incarnation's leftover goroutines
+// never reach the Persister that the restarted server reads.
+//
+
+import (
+    "math/rand"
+    "sync"
+)
+
+// what survives of a Persister's most recent write when the
+// server crashes.
+type CrashFault int
+
+const (
+    CrashClean         CrashFault = iota // every completed write survives
+    CrashLoseLastWrite                   // the most recent write is lost entirely
+    CrashTearLastWrite                   // only a prefix of the most recent raft state reaches disk, and its snapshot is lost
+)
+
+type Persister struct {
+    mu        sync.Mutex
+    raftState []byte
+    snapshot  []byte
+
+    // what was there before the most recent write,
+    // for simulating lost and torn writes.
+    prevRaftState []byte
+    prevSnapshot  []byte
+    fault         CrashFault
+}
+
+func MakePersister() *Persister {
+    return &Persister{}
+}
+
+func clone(orig []byte) []byte {
+    x := make([]byte, len(orig))
+    copy(x, orig)
+    return x
+}
+
+// an independent copy, as a restarted server would find it
+// after a clean shutdown.
+func (persister *Persister) Copy() *Persister {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+
+    np := MakePersister()
+    np.raftState = clone(persister.raftState)
+    np.snapshot = clone(persister.snapshot)
+    // what survived is the last write as far as another crash
+    // is concerned.
+    np.prevRaftState = clone(np.raftState)
+    np.prevSnapshot = clone(np.snapshot)
+    np.fault = persister.fault
+    return np
+}
+
+// an independent copy, as a restarted server would find it
+// after a crash, with the configured CrashFault applied to
+// the most recent write.
+func (persister *Persister) Crash() *Persister {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+
+    np := MakePersister()
+    np.fault = persister.fault
+    switch persister.fault {
+    case CrashLoseLastWrite:
+        np.raftState = clone(persister.prevRaftState)
+        np.snapshot = clone(persister.prevSnapshot)
+    case CrashTearLastWrite:
+        // the new bytes got as far as n, the rest of the
+        // file still holds whatever was there before.
+        n := rand.Intn(len(persister.raftState) + 1)
+        torn := clone(persister.raftState[:n])
+        if n < len(persister.prevRaftState) {
+            torn = append(torn, persister.prevRaftState[n:]...)
+        }
+        np.raftState = torn
+        np.snapshot = clone(persister.prevSnapshot)
+    default:
+        np.raftState = clone(persister.raftState)
+        np.snapshot = clone(persister.snapshot)
+    }
+    np.prevRaftState = clone(np.raftState)
+    np.prevSnapshot = clone(np.snapshot)
+    return np
+}
+
+func (persister *Persister) SetCrashFault(fault CrashFault) {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+    persister.fault = fault
+}
+
+func (persister *Persister) ReadRaftState() []byte {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+    return clone(persister.raftState)
+}
+
+func (persister *Persister) RaftStateSize() int {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+    return len(persister.raftState)
+}
+
+func (persister *Persister) ReadSnapshot() []byte {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+    return clone(persister.snapshot)
+}
+
+func (persister *Persister) SnapshotSize() int {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+    return len(persister.snapshot)
+}
+
+// save Raft state, leaving the snapshot alone.
+func (persister *Persister) SaveRaftState(state []byte) {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+
+    persister.prevRaftState = persister.raftState
+    persister.prevSnapshot = persister.snapshot
+    persister.raftState = clone(state)
+}
+
+// save both Raft state and K/V snapshot as a single atomic action,
+// to help avoid them getting out of sync.
+func (persister *Persister) SaveStateAndSnapshot(state []byte, snapshot []byte) {
+    persister.mu.Lock()
+    defer persister.mu.Unlock()
+
+    persister.prevRaftState = persister.raftState
+    persister.prevSnapshot = persister.snapshot
+    persister.raftState = clone(state)
+    persister.snapshot = clone(snapshot)
+}
+
+// give serverName a Persister, which DeleteServer() and
+// CrashServer() will supersede with a copy.
+func (network *Network) SetPersister(serverName interface{}, persister *Persister) {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    network.persisters[serverName] = persister
+}
+
+// the Persister a (re)started serverName should use,
+// or nil if none was set.
+func (network *Network) GetPersister(serverName interface{}) *Persister {
+    network.mu.Lock()
+    defer network.mu.Unlock()
+
+    return network.persisters[serverName]
+}
@@ -0,0 +1,116 @@
+package labrpc
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestPersister(t *testing.T) {
+    persister := MakePersister()
+    persister.SaveStateAndSnapshot([]byte("state-1"), []byte("snap-1"))
+
+    if persister.RaftStateSize() != 7 || persister.SnapshotSize() != 6 {
+        t.Fatalf("unexpected sizes %d, %d", persister.RaftStateSize(), persister.SnapshotSize())
+    }
+
+    state := persister.ReadRaftState()
+    state[0] = 'X'
+    if !bytes.Equal(persister.ReadRaftState(), []byte("state-1")) {
+        t.Fatalf("ReadRaftState() must return a copy")
+    }
+
+    np := persister.Copy()
+    persister.SaveRaftState([]byte("state-2"))
+    if !bytes.Equal(np.ReadRaftState(), []byte("state-1")) || !bytes.Equal(np.ReadSnapshot(), []byte("snap-1")) {
+        t.Fatalf("Copy() must be independent of later writes")
+    }
+    if !bytes.Equal(persister.ReadSnapshot(), []byte("snap-1")) {
+        t.Fatalf("SaveRaftState() must leave the snapshot alone")
+    }
+}
+
+func TestPersisterCrashFaults(t *testing.T) {
+    persister := MakePersister()
+    persister.SaveStateAndSnapshot([]byte("old-state-old"), []byte("old-snap"))
+    persister.SaveStateAndSnapshot([]byte("new-state"), []byte("new-snap"))
+
+    if !bytes.Equal(persister.Crash().ReadRaftState(), []byte("new-state")) {
+        t.Fatalf("a clean crash must keep the last write")
+    }
+
+    persister.SetCrashFault(CrashLoseLastWrite)
+    lost := persister.Crash()
+    if !bytes.Equal(lost.ReadRaftState(), []byte("old-state-old")) || !bytes.Equal(lost.ReadSnapshot(), []byte("old-snap")) {
+        t.Fatalf("expected last write to be lost, got %q", lost.ReadRaftState())
+    }
+
+    persister.SetCrashFault(CrashTearLastWrite)
+    for i := 0; i < 20; i++ {
+        torn := persister.Crash().ReadRaftState()
+        // some prefix of the new state, followed by the rest of the old.
+        ok := false
+        for n := 0; n <= len("new-state"); n++ {
+            if string(torn) == "new-state"[:n]+"old-state-old"[n:] {
+                ok = true
+            }
+        }
+        if !ok {
+            t.Fatalf("unexpected torn state %q", torn)
+        }
+    }
+}
+
+func TestPersisterCrashTwice(t *testing.T) {
+    persister := MakePersister()
+    persister.SaveStateAndSnapshot([]byte("old-state"), []byte("old-snap"))
+    persister.SaveStateAndSnapshot([]byte("new-state"), []byte("new-snap"))
+
+    // whatever survived the first crash, or a clean restart,
+    // must survive a second crash with no write in between.
+    for _, fault := range []CrashFault{CrashClean, CrashLoseLastWrite, CrashTearLastWrite} {
+        persister.SetCrashFault(fault)
+        for _, restarted := range []*Persister{persister.Crash(), persister.Copy()} {
+            state, snapshot := restarted.ReadRaftState(), restarted.ReadSnapshot()
+            again := restarted.Crash()
+            if !bytes.Equal(again.ReadRaftState(), state) || !bytes.Equal(again.ReadSnapshot(), snapshot) {
+                t.Fatalf("fault %v: %q, %q became %q, %q after a second crash",
+                    fault, state, snapshot, again.ReadRaftState(), again.ReadSnapshot())
+            }
+        }
+    }
+}
+
+func TestPersisterDeleteServer(t *testing.T) {
+    network := MakeNetwork()
+    defer network.Cleanup()
+
+    network.AddServer("server-42", MakeServer())
+    network.SetPersister("server-42", MakePersister())
+
+    old := network.GetPersister("server-42")
+    old.SaveRaftState([]byte("before"))
+
+    network.DeleteServer("server-42")
+
+    // the killed server's leftover goroutines keep writing.
+    old.SaveRaftState([]byte("after"))
+
+    if !bytes.Equal(network.GetPersister("server-42").ReadRaftState(), []byte("before")) {
+        t.Fatalf("writes by a deleted server reached its successor's Persister")
+    }
+
+    server := MakeServer()
+    network.AddServer("server-42", server)
+
+    old = network.GetPersister("server-42")
+    old.SetCrashFault(CrashLoseLastWrite)
+    old.SaveRaftState([]byte("unsynced"))
+
+    network.CrashServer("server-42")
+    old.SaveRaftState([]byte("after crash"))
+    network.RestartServer("server-42", nil)
+
+    if !bytes.Equal(network.GetPersister("server-42").ReadRaftState(), []byte("before")) {
+        t.Fatalf("expected crash to lose the last write, got %q", network.GetPersister("server-42").ReadRaftState())
+    }
+}
@@ -0,0 +1,268 @@
+package labrpc
+
+//
+// per-method, per-server and per-end RPC statistics, so that tests
+// can make performance assertions such as "no more than N
+// AppendEntries per second while idle":
+//
+//    before := network.Stats()
+//    time.Sleep(time.Second)
+//    idle := network.Stats().Sub(before)
+//    if idle.Rate("Raft.AppendEntries") > 30 { ... }
+//
+
+import (
+    "math"
+    "sync"
+    "time"
+)
+
+// upper bounds of the latency histogram buckets; the
+// last bucket of a Histogram counts everything slower.
+var LatencyBuckets = []time.Duration{
+    1 * time.Millisecond,
+    2 * time.Millisecond,
+    5 * time.Millisecond,
+    10 * time.Millisecond,
+    20 * time.Millisecond,
+    50 * time.Millisecond,
+    100 * time.Millisecond,
+    200 * time.Millisecond,
+    500 * time.Millisecond,
+    1 * time.Second,
+    2 * time.Second,
+    5 * time.Second,
+}
+
+// Counts[i] is the number of RPCs that took at most LatencyBuckets[i]
+// (and more than LatencyBuckets[i-1]); Counts[len(LatencyBuckets)]
+// counts the RPCs slower than all of them.
+type Histogram struct {
+    Counts []int64
+    Sum    time.Duration
+}
+
+func makeHistogram() Histogram {
+    return Histogram{Counts: make([]int64, len(LatencyBuckets)+1)}
+}
+
+func (histogram *Histogram) add(latency time.Duration) {
+    i := 0
+    for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+        i++
+    }
+    histogram.Counts[i] += 1
+    histogram.Sum += latency
+}
+
+func (histogram Histogram) copy() Histogram {
+    c := makeHistogram()
+    copy(c.Counts, histogram.Counts)
+    c.Sum = histogram.Sum
+    return c
+}
+
+func (histogram Histogram) sub(earlier Histogram) Histogram {
+    c := histogram.copy()
+    for i := range earlier.Counts {
+        c.Counts[i] -= earlier.Counts[i]
+    }
+    c.Sum -= earlier.Sum
+    return c
+}
+
+// an upper bound on the latency of fraction p (0 < p <= 1) of the RPCs,
+// to the resolution of LatencyBuckets. a percentile that falls in the
+// last bucket is reported as the mean latency if that is larger than
+// the last bound, since the bucket has no upper bound of its own.
+func (histogram Histogram) Percentile(p float64) time.Duration {
+    total := int64(0)
+    for _, n := range histogram.Counts {
+        total += n
+    }
+    if total == 0 {
+        return 0
+    }
+
+    // the rank of the RPC at p, rounded up: of 3, p=0.5 is the 2nd.
+    // the slack keeps 0.7 of 10 from being taken as 7.000000000000001.
+    want := int64(math.Ceil(p*float64(total) - 1e-9))
+    if want < 1 {
+        want = 1
+    }
+    seen := int64(0)
+    for i, n := range histogram.Counts {
+        seen += n
+        if seen >= want && i < len(LatencyBuckets) {
+            return LatencyBuckets[i]
+        }
+    }
+
+    last := LatencyBuckets[len(LatencyBuckets)-1]
+    if mean := histogram.Sum / time.Duration(total); mean > last {
+        return mean
+    }
+    return last
+}
+
+type RPCStats struct {
+    Count   int64 // RPCs sent
+    Failed  int64 // RPCs that got no reply: dropped, timed out, server killed
+    Bytes   int64 // bytes of requests and delivered replies
+    Latency Histogram
+}
+
+func makeRPCStats() RPCStats {
+    return RPCStats{Latency: makeHistogram()}
+}
+
+func (rpcStats *RPCStats) add(bytes int, ok bool, latency time.Duration) {
+    rpcStats.Count += 1
+    if !ok {
+        rpcStats.Failed += 1
+    }
+    rpcStats.Bytes += int64(bytes)
+    rpcStats.Latency.add(latency)
+}
+
+func (rpcStats RPCStats) copy() RPCStats {
+    c := rpcStats
+    c.Latency = rpcStats.Latency.copy()
+    return c
+}
+
+func (rpcStats RPCStats) sub(earlier RPCStats) RPCStats {
+    c := rpcStats
+    c.Count -= earlier.Count
+    c.Failed -= earlier.Failed
+    c.Bytes -= earlier.Bytes
+    c.Latency = rpcStats.Latency.sub(earlier.Latency)
+    return c
+}
+
+// a point-in-time copy of a Network's statistics.
+type StatsSnapshot struct {
+    Elapsed time.Duration // since the statistics were last reset
+    Total   RPCStats
+    Methods map[string]RPCStats      // "Raft.AppendEntries" -> stats
+    Servers map[interface{}]RPCStats // serverName -> stats
+    Ends    map[interface{}]RPCStats // endName -> stats
+}
+
+// the statistics of what happened between earlier and snapshot,
+// for instance between two phases of a test.
+func (snapshot StatsSnapshot) Sub(earlier StatsSnapshot) StatsSnapshot {
+    diff := StatsSnapshot{}
+    diff.Elapsed = snapshot.Elapsed - earlier.Elapsed
+    diff.Total = snapshot.Total.sub(earlier.Total)
+    diff.Methods = map[string]RPCStats{}
+    for k, v := range snapshot.Methods {
+        diff.Methods[k] = v.sub(earlier.Methods[k])
+    }
+    diff.Servers = subRPCStatsMap(snapshot.Servers, earlier.Servers)
+    diff.Ends = subRPCStatsMap(snapshot.Ends, earlier.Ends)
+    return diff
+}
+
+func subRPCStatsMap(later map[interface{}]RPCStats, earlier map[interface{}]RPCStats) map[interface{}]RPCStats {
+    diff := map[interface{}]RPCStats{}
+    for k, v := range later {
+        if e, ok := earlier[k]; ok {
+            diff[k] = v.sub(e)
+        } else {
+            diff[k] = v.copy()
+        }
+    }
+    return diff
+}
+
+// calls of serviceMethod per second over the snapshot's Elapsed time.
+func (snapshot StatsSnapshot) Rate(serviceMethod string) float64 {
+    if snapshot.Elapsed <= 0 {
+        return 0
+    }
+    return float64(snapshot.Methods[serviceMethod].Count) / snapshot.Elapsed.Seconds()
+}
+
+type netStats struct {
+    mu      sync.Mutex
+    since   time.Time
+    total   RPCStats
+    methods map[string]*RPCStats
+    servers map[interface{}]*RPCStats
+    ends    map[interface{}]*RPCStats
+}
+
+func makeNetStats() *netStats {
+    stats := &netStats{}
+    stats.reset()
+    return stats
+}
+
+func (stats *netStats) reset() {
+    stats.since = time.Now()
+    stats.total = makeRPCStats()
+    stats.methods = map[string]*RPCStats{}
+    stats.servers = map[interface{}]*RPCStats{}
+    stats.ends = map[interface{}]*RPCStats{}
+}
+
+func (stats *netStats) record(
+    endName interface{}, serverName interface{}, serviceMethod string,
+    requestBytes int, replyBytes int, ok bool, latency time.Duration,
+) {
+    stats.mu.Lock()
+    defer stats.mu.Unlock()
+
+    bytes := requestBytes + replyBytes
+    stats.total.add(bytes, ok, latency)
+    statsFor(stats.methods, serviceMethod).add(bytes, ok, latency)
+    statsFor(stats.ends, endName).add(bytes, ok, latency)
+    if serverName != nil {
+        // a request on an unconnected end never reaches any server.
+        statsFor(stats.servers, serverName).add(bytes, ok, latency)
+    }
+}
+
+func statsFor[K comparable](m map[K]*RPCStats, k K) *RPCStats {
+    rpcStats, ok := m[k]
+    if !ok {
+        s := makeRPCStats()
+        rpcStats = &s
+        m[k] = rpcStats
+    }
+    return rpcStats
+}
+
+func (network *Network) Stats() StatsSnapshot {
+    stats := network.stats
+    stats.mu.Lock()
+    defer stats.mu.Unlock()
+
+    snapshot := StatsSnapshot{}
+    snapshot.Elapsed = time.Since(stats.since)
+    snapshot.Total = stats.total.copy()
+    snapshot.Methods = map[string]RPCStats{}
+    for k, v := range stats.methods {
+        snapshot.Methods[k] = v.copy()
+    }
+    snapshot.Servers = map[interface{}]RPCStats{}
+    for k, v := range stats.servers {
+        snapshot.Servers[k] = v.copy()
+    }
+    snapshot.Ends = map[interface{}]RPCStats{}
+    for k, v := range stats.ends {
+        snapshot.Ends[k] = v.copy()
+    }
+    return snapshot
+}
+
+// forget the statistics gathered so far. the totals reported by
+// GetTotalCount() and GetTotalBytes() are not affected.
+func (network *Network) ResetStats() {
+    stats := network.stats
+    stats.mu.Lock()
+    defer stats.mu.Unlock()
+
+    stats.reset()
+}
@@ -0,0 +1,112 @@
+package labrpc
+
+import (
+    "runtime"
+    "testing"
+    "time"
+)
+
+func TestStats(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(2)
+    defer network.Cleanup()
+
+    for i := 0; i < 5; i++ {
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", i, &reply)
+    }
+    for i := 0; i < 3; i++ {
+        var reply int
+        ends[1].Call("JunkServer.HandlerSleep", 30, &reply)
+    }
+
+    snapshot := network.Stats()
+
+    if snapshot.Total.Count != 8 {
+        t.Fatalf("expected 8 RPCs, got %d", snapshot.Total.Count)
+    }
+    if n := snapshot.Methods["JunkServer.HandlerIntToString"].Count; n != 5 {
+        t.Fatalf("expected 5 HandlerIntToString, got %d", n)
+    }
+    if n := snapshot.Servers["server-1"].Count; n != 3 {
+        t.Fatalf("expected 3 RPCs to server-1, got %d", n)
+    }
+    if n := snapshot.Ends["end-0"].Count; n != 5 {
+        t.Fatalf("expected 5 RPCs from end-0, got %d", n)
+    }
+    if snapshot.Total.Bytes == 0 || snapshot.Total.Failed != 0 {
+        t.Fatalf("unexpected totals %+v", snapshot.Total)
+    }
+
+    sleep := snapshot.Methods["JunkServer.HandlerSleep"].Latency
+    if p := sleep.Percentile(0.5); p < 30*time.Millisecond {
+        t.Fatalf("expected median HandlerSleep latency of at least 30ms, got %v", p)
+    }
+    if p := snapshot.Methods["JunkServer.HandlerIntToString"].Latency.Percentile(1); p > 20*time.Millisecond {
+        t.Fatalf("expected HandlerIntToString to be fast, got %v", p)
+    }
+
+    // a second phase, diffed against the first.
+    network.Enable("end-0", false)
+    for i := 0; i < 2; i++ {
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", i, &reply)
+    }
+
+    diff := network.Stats().Sub(snapshot)
+    if diff.Total.Count != 2 || diff.Total.Failed != 2 {
+        t.Fatalf("expected 2 failed RPCs in second phase, got %+v", diff.Total)
+    }
+    if n := diff.Methods["JunkServer.HandlerSleep"].Count; n != 0 {
+        t.Fatalf("expected no HandlerSleep in second phase, got %d", n)
+    }
+    if diff.Rate("JunkServer.HandlerIntToString") <= 0 {
+        t.Fatalf("expected a positive HandlerIntToString rate")
+    }
+
+    network.ResetStats()
+    if n := network.Stats().Total.Count; n != 0 {
+        t.Fatalf("expected 0 RPCs after reset, got %d", n)
+    }
+    if network.GetTotalCount() != 10 {
+        t.Fatalf("expected reset to leave total count alone, got %d", network.GetTotalCount())
+    }
+}
+
+func TestPercentile(t *testing.T) {
+    histogram := makeHistogram()
+    histogram.add(1 * time.Millisecond)
+    histogram.add(5 * time.Millisecond)
+    histogram.add(50 * time.Millisecond)
+
+    // an odd count: the median is the 2nd of 3.
+    cases := []struct {
+        p    float64
+        want time.Duration
+    }{
+        {0.1, 1 * time.Millisecond},
+        {0.34, 5 * time.Millisecond},
+        {0.5, 5 * time.Millisecond},
+        {0.67, 50 * time.Millisecond},
+        {1, 50 * time.Millisecond},
+    }
+    for _, c := range cases {
+        if got := histogram.Percentile(c.p); got != c.want {
+            t.Fatalf("Percentile(%v): want %v, got %v", c.p, c.want, got)
+        }
+    }
+
+    // 7 of 10 fast RPCs, so p=0.7 is still fast.
+    histogram = makeHistogram()
+    for i := 0; i < 10; i++ {
+        if i < 7 {
+            histogram.add(1 * time.Millisecond)
+        } else {
+            histogram.add(50 * time.Millisecond)
+        }
+    }
+    if got := histogram.Percentile(0.7); got != 1*time.Millisecond {
+        t.Fatalf("Percentile(0.7): want 1ms, got %v", got)
+    }
+}
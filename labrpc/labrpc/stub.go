@@ -0,0 +1,24 @@
+package labrpc
+
+import (
+    "context"
+)
+
+// a typed stub for serviceMethod on clientEnd, so that the types of
+// the argument and reply are checked by the compiler rather than at
+// run time by labgob:
+//
+//    intToString := labrpc.Method[int, string](clientEnd, "JunkServer.HandlerIntToString")
+//    reply, err := intToString(ctx, 42)
+//
+// each call decodes into a fresh Reply, so the reply never holds
+// non-default values from an earlier call. errors are as for Invoke.
+// the service method name is still a string; see cmd/labrpcgen for
+// stubs whose names are checked too.
+func Method[Args any, Reply any](clientEnd *ClientEnd, serviceMethod string) func(ctx context.Context, args Args) (Reply, error) {
+    return func(ctx context.Context, args Args) (Reply, error) {
+        var reply Reply
+        err := clientEnd.Invoke(ctx, serviceMethod, args, &reply)
+        return reply, err
+    }
+}
@@ -0,0 +1,34 @@
+package labrpc
+
+import (
+    "context"
+    "errors"
+    "runtime"
+    "testing"
+)
+
+func TestMethod(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    intToString := Method[int, string](ends[0], "JunkServer.HandlerIntToString")
+    stringToInt := Method[string, int](ends[0], "JunkServer.HandlerStringToInt")
+    withPointer := Method[*JunkArgs, JunksReply](ends[0], "JunkServer.HandlerWithPointer")
+
+    if reply, err := intToString(context.Background(), 42); err != nil || reply != "42" {
+        t.Fatalf("expected reply to be 42, got %q, %v", reply, err)
+    }
+    if reply, err := stringToInt(context.Background(), "42"); err != nil || reply != 42 {
+        t.Fatalf("expected reply to be 42, got %d, %v", reply, err)
+    }
+    if reply, err := withPointer(context.Background(), &JunkArgs{}); err != nil || reply.X != "pointer" {
+        t.Fatalf("expected reply to be pointer, got %q, %v", reply.X, err)
+    }
+
+    network.Enable("end-0", false)
+    if _, err := intToString(context.Background(), 42); !errors.Is(err, ErrNoReply) {
+        t.Fatalf("expected ErrNoReply, got %v", err)
+    }
+}
@@ -0,0 +1,311 @@
+package labrpc
+
+//
+// an optional record of every request that passes through a
+// Network, and what became of it, so that a failed distributed
+// test can be inspected after the fact:
+//
+//    network.StartTrace()
+//    ... run the test ...
+//    events := network.StopTrace()
+//    labrpc.WriteChromeTrace(f, events) // open in chrome://tracing or ui.perfetto.dev
+//
+// a trace can also be replayed into a fresh Network, re-sending
+// the recorded requests with their original spacing.
+//
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "lab-rpc/labgob"
+    "reflect"
+    "sort"
+    "sync"
+    "time"
+)
+
+// what the network did with a request.
+type Fate string
+
+const (
+    FateDelivered      Fate = "delivered"       // reply delivered
+    FateDelayed        Fate = "delayed"         // reply delivered after DelayMs of reordering delay
+    FateDroppedRequest Fate = "dropped-request" // unreliable network lost the request
+    FateDroppedReply   Fate = "dropped-reply"   // unreliable network lost the reply
+    FateUnreachable    Fate = "unreachable"     // end disabled or unconnected, or no server; timed out after DelayMs
+    FateServerDead     Fate = "server-dead"     // server was deleted while the handler ran
+    FateCallerGone     Fate = "caller-gone"     // the caller's context was done before the reply
+    FateTooLarge       Fate = "too-large"       // request or reply exceeded the maximum message size
+)
+
+type TraceEvent struct {
+    Seq        int64       `json:"seq"`
+    End        interface{} `json:"end"`
+    Server     interface{} `json:"server"`
+    Method     string      `json:"method"`
+    Args       interface{} `json:"args"`     // decoded, if the caller's type is known
+    RawArgs    []byte      `json:"raw_args"` // labgob-encoded, for Replay
+    Codec      string      `json:"codec"`    // name of RawArgs' labgob.Codec
+    ReplyBytes int         `json:"reply_bytes"`
+    Ok         bool        `json:"ok"`
+    Fate       Fate        `json:"fate"`
+    DelayMs    int64       `json:"delay_ms"`
+    Start      time.Time   `json:"start"`  // request reached the network
+    Finish     time.Time   `json:"finish"` // outcome handed back to the caller
+}
+
+type tracer struct {
+    mu      sync.Mutex
+    enabled bool
+    seq     int64
+    events  []TraceEvent
+}
+
+func (tracer *tracer) record(
+    req requestMessage, serverName interface{}, start time.Time,
+    res responseMessage, fate Fate, delay time.Duration,
+) {
+    tracer.mu.Lock()
+    defer tracer.mu.Unlock()
+
+    if !tracer.enabled {
+        return
+    }
+
+    event := TraceEvent{}
+    tracer.seq += 1
+    event.Seq = tracer.seq
+    event.End = req.endName
+    event.Server = serverName
+    event.Method = req.serviceMethod
+    event.Args = decodeTraceArgs(req)
+    event.RawArgs = req.args
+    event.Codec = req.codec.Name()
+    event.ReplyBytes = len(res.reply)
+    event.Ok = res.ok
+    event.Fate = fate
+    event.DelayMs = delay.Milliseconds()
+    event.Start = start
+    event.Finish = time.Now()
+    tracer.events = append(tracer.events, event)
+}
+
+func decodeTraceArgs(req requestMessage) interface{} {
+    if req.argsType == nil {
+        return nil
+    }
+    args := reflect.New(req.argsType)
+    if err := req.stream().Decode(bytes.NewReader(req.args), args.Interface()); err != nil {
+        return nil
+    }
+    return args.Elem().Interface()
+}
+
+// start recording a trace, discarding any earlier one.
+func (network *Network) StartTrace() {
+    network.tracer.mu.Lock()
+    defer network.tracer.mu.Unlock()
+
+    network.tracer.enabled = true
+    network.tracer.seq = 0
+    network.tracer.events = nil
+}
+
+// stop recording, and return the trace in order of completion.
+func (network *Network) StopTrace() []TraceEvent {
+    network.tracer.mu.Lock()
+    defer network.tracer.mu.Unlock()
+
+    network.tracer.enabled = false
+    events := network.tracer.events
+    network.tracer.events = nil
+    return events
+}
+
+// a copy of the trace recorded so far.
+func (network *Network) Trace() []TraceEvent {
+    network.tracer.mu.Lock()
+    defer network.tracer.mu.Unlock()
+
+    return append([]TraceEvent{}, network.tracer.events...)
+}
+
+// write the trace as JSON lines, one TraceEvent per line.
+func WriteTraceJSON(w io.Writer, events []TraceEvent) error {
+    encoder := json.NewEncoder(w)
+    for _, event := range events {
+        if err := encoder.Encode(event); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// read a trace written by WriteTraceJSON. end and server names
+// come back as whatever encoding/json makes of them, e.g. a
+// string stays a string but an int becomes a float64.
+func ReadTraceJSON(r io.Reader) ([]TraceEvent, error) {
+    events := []TraceEvent{}
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(nil, 64<<20)
+    for scanner.Scan() {
+        var event TraceEvent
+        if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+            return nil, err
+        }
+        events = append(events, event)
+    }
+    return events, scanner.Err()
+}
+
+type chromeEvent struct {
+    Name string                 `json:"name"`
+    Cat  string                 `json:"cat,omitempty"`
+    Ph   string                 `json:"ph"`
+    Ts   int64                  `json:"ts"`
+    Dur  int64                  `json:"dur"`
+    Pid  int                    `json:"pid"`
+    Tid  int                    `json:"tid"`
+    Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// write the trace in the Chrome trace-event format, which
+// chrome://tracing, ui.perfetto.dev and Jaeger's importer can
+// display. each server is a process and each end a thread;
+// an RPC is a slice from Start to Finish, categorized by its Fate.
+func WriteChromeTrace(w io.Writer, events []TraceEvent) error {
+    pids := map[string]int{}    // serverName -> pid
+    tids := map[[2]string]int{} // (serverName, endName) -> tid
+    chromeEvents := []chromeEvent{}
+
+    var origin time.Time
+    for _, event := range events {
+        if origin.IsZero() || event.Start.Before(origin) {
+            origin = event.Start
+        }
+    }
+
+    for _, event := range events {
+        server := "no server"
+        if event.Server != nil {
+            server = fmt.Sprint(event.Server)
+        }
+        pid, ok := pids[server]
+        if !ok {
+            pid = len(pids) + 1
+            pids[server] = pid
+            chromeEvents = append(chromeEvents, chromeEvent{
+                Name: "process_name", Ph: "M", Pid: pid,
+                Args: map[string]interface{}{"name": server},
+            })
+        }
+
+        end := fmt.Sprint(event.End)
+        tid, ok := tids[[2]string{server, end}]
+        if !ok {
+            tid = len(tids) + 1
+            tids[[2]string{server, end}] = tid
+            chromeEvents = append(chromeEvents, chromeEvent{
+                Name: "thread_name", Ph: "M", Pid: pid, Tid: tid,
+                Args: map[string]interface{}{"name": end},
+            })
+        }
+
+        chromeEvents = append(chromeEvents, chromeEvent{
+            Name: event.Method,
+            Cat:  string(event.Fate),
+            Ph:   "X",
+            Ts:   event.Start.Sub(origin).Microseconds(),
+            Dur:  event.Finish.Sub(event.Start).Microseconds(),
+            Pid:  pid,
+            Tid:  tid,
+            Args: map[string]interface{}{
+                "seq":         event.Seq,
+                "end":         event.End,
+                "args":        event.Args,
+                "ok":          event.Ok,
+                "fate":        event.Fate,
+                "delay_ms":    event.DelayMs,
+                "reply_bytes": event.ReplyBytes,
+            },
+        })
+    }
+
+    return json.NewEncoder(w).Encode(map[string]interface{}{
+        "traceEvents":     chromeEvents,
+        "displayTimeUnit": "ms",
+    })
+}
+
+// re-send the requests of a recorded trace through the network,
+// from the same ends, in their original order and with their
+// original spacing, and wait for all of them to finish. a request
+// that was sent after another had finished in the recording is not
+// re-sent until that one has finished again, so handlers see the
+// same sequence of arguments as during the recording, although an
+// unreliable network may again drop or delay messages. ok[i]
+// reports whether the i'th request, in order of Start, got a
+// reply; replies themselves are discarded. a request whose codec
+// isn't registered in this process is not sent, and is not ok.
+func (network *Network) Replay(events []TraceEvent) (ok []bool) {
+    events = append([]TraceEvent{}, events...)
+    sort.SliceStable(events, func(i, j int) bool {
+        return events[i].Start.Before(events[j].Start)
+    })
+
+    ok = make([]bool, len(events))
+    if len(events) == 0 {
+        return ok
+    }
+
+    finished := make([]chan struct{}, len(events))
+    origin := events[0].Start
+    replayStart := time.Now()
+    for i, event := range events {
+        time.Sleep(time.Until(replayStart.Add(event.Start.Sub(origin))))
+        for j := 0; j < i; j++ {
+            if events[j].Finish.Before(event.Start) {
+                <-finished[j]
+            }
+        }
+
+        finished[i] = make(chan struct{})
+        codec, known := labgob.LookupCodec(event.Codec)
+        if !known {
+            // recorded with a codec this process doesn't have.
+            close(finished[i])
+            continue
+        }
+
+        req := requestMessage{}
+        req.ctx = context.Background()
+        req.endName = event.End
+        req.serviceMethod = event.Method
+        req.args = event.RawArgs
+        req.codec = codec
+        _, req.strict = network.codec.get()
+        req.responseMessageChan = make(chan responseMessage, 1)
+
+        select {
+        case network.requestMessageChan <- req:
+        case <-network.done:
+            // entire Network has been destroyed.
+            return make([]bool, len(events))
+        }
+
+        go func(i int) {
+            ok[i] = (<-req.responseMessageChan).ok
+            close(finished[i])
+        }(i)
+    }
+
+    for i := range finished {
+        <-finished[i]
+    }
+
+    return ok
+}
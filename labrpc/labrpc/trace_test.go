@@ -0,0 +1,162 @@
+package labrpc
+
+import (
+    "bytes"
+    "encoding/json"
+    "lab-rpc/labgob"
+    "reflect"
+    "runtime"
+    "testing"
+)
+
+func TestTrace(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(2)
+    defer network.Cleanup()
+
+    network.StartTrace()
+
+    {
+        var reply JunksReply
+        ends[0].Call("JunkServer.HandlerWithoutPointer", JunkArgs{X: 7}, &reply)
+    }
+    network.Enable("end-1", false)
+    {
+        var reply string
+        ends[1].Call("JunkServer.HandlerIntToString", 42, &reply)
+    }
+
+    events := network.StopTrace()
+    if len(events) != 2 {
+        t.Fatalf("expected 2 trace events, got %d", len(events))
+    }
+
+    delivered := events[0]
+    if delivered.End != "end-0" || delivered.Server != "server-0" ||
+        delivered.Method != "JunkServer.HandlerWithoutPointer" ||
+        delivered.Fate != FateDelivered || !delivered.Ok {
+        t.Fatalf("unexpected trace event %+v", delivered)
+    }
+    if args, ok := delivered.Args.(JunkArgs); !ok || args.X != 7 {
+        t.Fatalf("expected decoded args JunkArgs{7}, got %#v", delivered.Args)
+    }
+    if delivered.Finish.Before(delivered.Start) {
+        t.Fatalf("expected Finish after Start")
+    }
+
+    if events[1].Fate != FateUnreachable || events[1].Ok {
+        t.Fatalf("expected unreachable event, got %+v", events[1])
+    }
+
+    // nothing is recorded after StopTrace().
+    {
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", 1, &reply)
+    }
+    if n := len(network.Trace()); n != 0 {
+        t.Fatalf("expected empty trace after StopTrace, got %d events", n)
+    }
+
+    var jsonLines bytes.Buffer
+    if err := WriteTraceJSON(&jsonLines, events); err != nil {
+        t.Fatalf("WriteTraceJSON: %v", err)
+    }
+    read, err := ReadTraceJSON(&jsonLines)
+    if err != nil {
+        t.Fatalf("ReadTraceJSON: %v", err)
+    }
+    if len(read) != 2 || read[1].Fate != FateUnreachable || !bytes.Equal(read[0].RawArgs, delivered.RawArgs) {
+        t.Fatalf("trace did not survive JSON lines round trip: %+v", read)
+    }
+
+    var chrome bytes.Buffer
+    if err := WriteChromeTrace(&chrome, events); err != nil {
+        t.Fatalf("WriteChromeTrace: %v", err)
+    }
+    var decoded struct {
+        TraceEvents []map[string]interface{} `json:"traceEvents"`
+    }
+    if err := json.Unmarshal(chrome.Bytes(), &decoded); err != nil {
+        t.Fatalf("chrome trace is not JSON: %v", err)
+    }
+    slices := 0
+    for _, e := range decoded.TraceEvents {
+        if e["ph"] == "X" {
+            slices += 1
+        }
+    }
+    if slices != 2 {
+        t.Fatalf("expected 2 chrome trace slices, got %d", slices)
+    }
+}
+
+func TestReplay(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    network.StartTrace()
+    for i := 0; i < 5; i++ {
+        if i == 3 {
+            // each event remembers its codec.
+            network.SetCodec(labgob.MsgPack)
+        }
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", i, &reply)
+    }
+    events := network.StopTrace()
+    if events[0].Codec != "gob" || events[4].Codec != "msgpack" {
+        t.Fatalf("expected codecs gob and msgpack, got %v and %v", events[0].Codec, events[4].Codec)
+    }
+
+    // replay into a fresh network with a fresh JunkServer.
+    replayNetwork := MakeNetwork()
+    defer replayNetwork.Cleanup()
+
+    junkServer := &JunkServer{}
+    server := MakeServer()
+    server.AddService(MakeService(junkServer))
+    replayNetwork.MakeEnd("end-0")
+    replayNetwork.AddServer("server-0", server)
+    replayNetwork.Connect("end-0", "server-0")
+    replayNetwork.Enable("end-0", true)
+
+    ok := replayNetwork.Replay(events)
+    for i := range ok {
+        if !ok[i] {
+            t.Fatalf("expected replayed request %d to succeed", i)
+        }
+    }
+
+    junkServer.mu.Lock()
+    defer junkServer.mu.Unlock()
+    if !reflect.DeepEqual(junkServer.logInt, []int{0, 1, 2, 3, 4}) {
+        t.Fatalf("expected handler to see 0..4 again, got %v", junkServer.logInt)
+    }
+}
+
+func TestReplayUnknownCodec(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    network, ends := makeBroadcastNetwork(1)
+    defer network.Cleanup()
+
+    network.StartTrace()
+    for i := 0; i < 3; i++ {
+        var reply string
+        ends[0].Call("JunkServer.HandlerIntToString", i, &reply)
+    }
+    events := network.StopTrace()
+    events[1].Codec = "protobuf"
+
+    before := network.GetTotalCount()
+    ok := network.Replay(events)
+    if !ok[0] || ok[1] || !ok[2] {
+        t.Fatalf("expected only the protobuf request to fail, got %v", ok)
+    }
+    if sent := network.GetTotalCount() - before; sent != 2 {
+        t.Fatalf("expected the protobuf request not to be sent, %d of 3 were", sent)
+    }
+}
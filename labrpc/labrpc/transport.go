@@ -0,0 +1,254 @@
+package labrpc
+
+//
+// a real-network backend, so that a Server whose handlers were
+// tested under the simulated Network can also be served over TCP
+// or a Unix socket, and called from a ClientEnd in another process.
+//
+// each connection carries a stream of labgob-encoded wireRequests
+// from the client and wireReplies from the server; the args and
+// replies inside them are encoded with the codec the client asked
+// for, gob unless it used DialCodec(). requests are
+// tagged with a sequence number, so that many calls can be in
+// flight on one connection and replies may arrive in any order.
+//
+
+import (
+    "context"
+    "lab-rpc/labgob"
+    "net"
+    "strings"
+    "sync"
+    "time"
+)
+
+type wireRequest struct {
+    Seq           uint64
+    ServiceMethod string
+    Args          []byte
+    Deadline      int64  // the caller's, in Unix nanoseconds, or 0
+    Codec         string // name of the labgob.Codec of Args and the reply, "" for gob
+}
+
+type wireReply struct {
+    Seq   uint64
+    Ok    bool
+    Reply []byte
+    Err   string
+}
+
+type remoteConn struct {
+    conn    net.Conn
+    encMu   sync.Mutex // serializes writes of wireRequests
+    encoder *labgob.LabEncoder
+    mu      sync.Mutex
+    seq     uint64
+    pending map[uint64]chan responseMessage // seq -> waiting CallContext
+    closed  bool
+    done    chan struct{} // closed when the connection is broken
+}
+
+// connect to a Server that is serving on the given network and
+// address, e.g. ("tcp", "localhost:1234") or ("unix", "/tmp/kv.sock").
+// the returned ClientEnd is used just like one made by
+// Network.MakeEnd, and should be closed when no longer needed.
+func Dial(network, address string) (*ClientEnd, error) {
+    return DialCodec(network, address, labgob.Gob)
+}
+
+// like Dial, but encode args and replies with codec, which
+// the server must also know by name; see labgob.RegisterCodec().
+func DialCodec(network, address string, codec labgob.Codec) (*ClientEnd, error) {
+    conn, err := net.Dial(network, address)
+    if err != nil {
+        return nil, err
+    }
+
+    remote := &remoteConn{}
+    remote.conn = conn
+    remote.encoder = labgob.NewEncoder(conn)
+    remote.pending = map[uint64]chan responseMessage{}
+    remote.done = make(chan struct{})
+    go remote.readReplies()
+
+    clientEnd := &ClientEnd{}
+    clientEnd.endName = conn.LocalAddr().String()
+    clientEnd.done = remote.done
+    clientEnd.remote = remote
+    clientEnd.codec = makeCodecSetting(codec)
+
+    return clientEnd, nil
+}
+
+// close the connection of a ClientEnd made by Dial.
+// outstanding and future calls return false.
+func (clientEnd *ClientEnd) Close() error {
+    if clientEnd.remote == nil {
+        return nil
+    }
+    return clientEnd.remote.conn.Close()
+}
+
+func (remote *remoteConn) send(req requestMessage) bool {
+    remote.mu.Lock()
+    if remote.closed {
+        remote.mu.Unlock()
+        return false
+    }
+    remote.seq += 1
+    seq := remote.seq
+    remote.pending[seq] = req.responseMessageChan
+    remote.mu.Unlock()
+
+    wreq := wireRequest{}
+    wreq.Seq = seq
+    wreq.ServiceMethod = req.serviceMethod
+    wreq.Args = req.args
+    if req.codec != labgob.Gob {
+        wreq.Codec = req.codec.Name()
+    }
+    if deadline, ok := req.ctx.Deadline(); ok {
+        wreq.Deadline = deadline.UnixNano()
+    }
+
+    remote.encMu.Lock()
+    err := remote.encoder.Encode(wreq)
+    remote.encMu.Unlock()
+
+    if err != nil {
+        remote.mu.Lock()
+        delete(remote.pending, seq)
+        remote.mu.Unlock()
+        remote.conn.Close()
+        return false
+    }
+    return true
+}
+
+// single goroutine per connection to deliver replies
+// to the CallContext()s waiting for them.
+func (remote *remoteConn) readReplies() {
+    decoder := labgob.NewDecoder(remote.conn)
+    for {
+        var wrep wireReply
+        if err := decoder.Decode(&wrep); err != nil {
+            break
+        }
+
+        remote.mu.Lock()
+        responseMessageChan := remote.pending[wrep.Seq]
+        delete(remote.pending, wrep.Seq)
+        remote.mu.Unlock()
+
+        if responseMessageChan != nil {
+            responseMessageChan <- responseMessage{wrep.Ok, wrep.Reply, wrep.Err}
+        }
+    }
+
+    // the connection is broken; fail every call still waiting.
+    remote.conn.Close()
+    remote.mu.Lock()
+    remote.closed = true
+    for seq, responseMessageChan := range remote.pending {
+        responseMessageChan <- responseMessage{false, nil, ""}
+        delete(remote.pending, seq)
+    }
+    remote.mu.Unlock()
+    close(remote.done)
+}
+
+// accept connections on listener and serve RPCs on each one,
+// until the listener is closed. the returned error is the one
+// from listener.Accept().
+func (server *Server) Serve(listener net.Listener) error {
+    for {
+        conn, err := listener.Accept()
+        if err != nil {
+            return err
+        }
+        go server.ServeConn(conn)
+    }
+}
+
+// serve RPCs on a single connection until the client hangs up.
+// each request runs in its own goroutine, as it would under
+// the simulated Network.
+func (server *Server) ServeConn(conn net.Conn) {
+    defer conn.Close()
+
+    decoder := labgob.NewDecoder(conn)
+    encoder := labgob.NewEncoder(conn)
+    var encMu sync.Mutex
+
+    // handlers that take a context learn when the client hangs up.
+    connCtx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    var wg sync.WaitGroup
+    defer wg.Wait()
+
+    for {
+        var wreq wireRequest
+        if err := decoder.Decode(&wreq); err != nil {
+            cancel()
+            return
+        }
+
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+
+            wrep := wireReply{}
+            wrep.Seq = wreq.Seq
+
+            // unlike a test calling through the simulated Network,
+            // a remote client must not be able to bring down the
+            // server by naming a method or codec that does not exist.
+            codec, ok := labgob.LookupCodec(wreq.Codec)
+            if ok && server.hasMethod(wreq.ServiceMethod) {
+                ctx := connCtx
+                if wreq.Deadline != 0 {
+                    var cancel context.CancelFunc
+                    ctx, cancel = context.WithDeadline(connCtx, time.Unix(0, wreq.Deadline))
+                    defer cancel()
+                }
+
+                req := requestMessage{}
+                req.ctx = ctx
+                req.endName = conn.RemoteAddr().String()
+                req.serviceMethod = wreq.ServiceMethod
+                req.args = wreq.Args
+                req.codec = codec
+                req.strict = labgob.Strict()
+
+                res := server.dispatch(req)
+                wrep.Ok = res.ok
+                wrep.Reply = res.reply
+                wrep.Err = res.err
+            }
+
+            encMu.Lock()
+            defer encMu.Unlock()
+            if err := encoder.Encode(wrep); err != nil {
+                conn.Close()
+            }
+        }()
+    }
+}
+
+func (server *Server) hasMethod(serviceMethod string) bool {
+    dot := strings.LastIndex(serviceMethod, ".")
+    if dot < 0 {
+        return false
+    }
+
+    server.mu.Lock()
+    service, ok := server.services[serviceMethod[:dot]]
+    server.mu.Unlock()
+
+    if !ok {
+        return false
+    }
+    _, ok = service.methods[serviceMethod[dot+1:]]
+    return ok
+}
@@ -0,0 +1,160 @@
+package labrpc
+
+import (
+    "context"
+    "lab-rpc/labgob"
+    "net"
+    "path/filepath"
+    "runtime"
+    "testing"
+    "time"
+)
+
+func serveJunk(t *testing.T, network, address string) net.Listener {
+    listener, err := net.Listen(network, address)
+    if err != nil {
+        t.Fatalf("listen: %v", err)
+    }
+
+    server := MakeServer()
+    server.AddService(MakeService(&JunkServer{}))
+    go server.Serve(listener)
+
+    return listener
+}
+
+func checkRemoteCalls(t *testing.T, clientEnd *ClientEnd) {
+    {
+        var reply string
+        if !clientEnd.Call("JunkServer.HandlerIntToString", 42, &reply) || reply != "42" {
+            t.Fatalf("expected reply to be 42, got %s", reply)
+        }
+    }
+
+    {
+        var reply int
+        if !clientEnd.Call("JunkServer.HandlerStringToInt", "42", &reply) || reply != 42 {
+            t.Fatalf("expected reply to be 42, got %d", reply)
+        }
+    }
+
+    {
+        var args JunkArgs
+        var reply JunksReply
+        if !clientEnd.Call("JunkServer.HandlerWithPointer", &args, &reply) || reply.X != "pointer" {
+            t.Fatalf("expected reply to be pointer, got %s", reply.X)
+        }
+    }
+
+    {
+        var reply string
+        if clientEnd.Call("JunkServer.NoSuchHandler", 42, &reply) {
+            t.Fatalf("expected call of an unknown method to fail")
+        }
+    }
+}
+
+func TestTCP(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener := serveJunk(t, "tcp", "127.0.0.1:0")
+    defer listener.Close()
+
+    clientEnd, err := Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    defer clientEnd.Close()
+
+    checkRemoteCalls(t, clientEnd)
+}
+
+func TestTCPCodec(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener := serveJunk(t, "tcp", "127.0.0.1:0")
+    defer listener.Close()
+
+    clientEnd, err := DialCodec("tcp", listener.Addr().String(), labgob.MsgPack)
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    defer clientEnd.Close()
+
+    checkRemoteCalls(t, clientEnd)
+}
+
+func TestUnixSocket(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener := serveJunk(t, "unix", filepath.Join(t.TempDir(), "junk.sock"))
+    defer listener.Close()
+
+    clientEnd, err := Dial("unix", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    defer clientEnd.Close()
+
+    checkRemoteCalls(t, clientEnd)
+}
+
+func TestTCPConcurrent(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener := serveJunk(t, "tcp", "127.0.0.1:0")
+    defer listener.Close()
+
+    clientEnd, err := Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    defer clientEnd.Close()
+
+    // replies to slow and fast handlers come back out of order
+    // on the one connection.
+    done := make(chan *Call, 20)
+    for i := 0; i < 20; i++ {
+        var reply int
+        clientEnd.Go("JunkServer.HandlerSleep", (20-i)*5, &reply, done)
+    }
+    for i := 0; i < 20; i++ {
+        call := <-done
+        if !call.Ok || *call.Reply.(*int) != call.Args.(int) {
+            t.Fatalf("expected reply to be %v, got %v", call.Args, *call.Reply.(*int))
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    var reply int
+    if clientEnd.CallContext(ctx, "JunkServer.HandlerSleep", 500, &reply) {
+        t.Fatalf("expected CallContext to fail at the deadline")
+    }
+}
+
+func TestTCPClosed(t *testing.T) {
+    runtime.GOMAXPROCS(4)
+
+    listener := serveJunk(t, "tcp", "127.0.0.1:0")
+    defer listener.Close()
+
+    clientEnd, err := Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+
+    // a call in flight when the connection goes away fails.
+    call := clientEnd.Go("JunkServer.HandlerSleep", 200, new(int), nil)
+    time.Sleep(20 * time.Millisecond)
+    clientEnd.Close()
+
+    if (<-call.Done).Ok {
+        t.Fatalf("expected in-flight call to fail after Close")
+    }
+
+    var reply string
+    if clientEnd.Call("JunkServer.HandlerIntToString", 42, &reply) {
+        t.Fatalf("expected call on closed end to fail")
+    }
+}
@@ -0,0 +1,353 @@
+package linearizability
+
+//
+// the search is the one from Lowe's "Testing for linearizability",
+// as used by Porcupine: walk the history in time order, tentatively
+// linearizing any operation whose call has been seen, and backtrack
+// when an operation returns without having been linearized. states
+// already visited with the same set of linearized operations are
+// cached, which prunes the search enormously in practice.
+//
+
+import (
+    "math"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+type CheckResult string
+
+const (
+    Ok      CheckResult = "Ok"
+    Illegal CheckResult = "Illegal"
+    Unknown CheckResult = "Unknown" // timed out
+)
+
+// what the checker found, for Visualize and WriteText.
+type LinearizationInfo struct {
+    model      Model
+    partitions []partitionInfo
+}
+
+type partitionInfo struct {
+    operations []Operation // indexed by id
+    ok         bool
+    longest    []int // ids, in order, of the longest linearizable prefix found
+    stuck      int   // id of an operation that could not be linearized after longest, or -1
+}
+
+type entryKind bool
+
+const (
+    callEntry   entryKind = false
+    returnEntry entryKind = true
+)
+
+type entry struct {
+    kind  entryKind
+    value interface{}
+    id    int
+    time  int64
+}
+
+// a doubly linked list of calls and returns, in time order; a
+// call's match is its return. linearizing an operation lifts both
+// of its nodes out of the list.
+type node struct {
+    value interface{}
+    match *node
+    id    int
+    next  *node
+    prev  *node
+}
+
+func makeEntries(history []Operation) []entry {
+    entries := []entry{}
+    for id, op := range history {
+        entries = append(entries, entry{callEntry, op.Input, id, op.Call})
+        entries = append(entries, entry{returnEntry, op.Output, id, op.Return})
+    }
+    // at equal times, calls go first, so that operations that touch
+    // are treated as concurrent.
+    sort.SliceStable(entries, func(i, j int) bool {
+        if entries[i].time != entries[j].time {
+            return entries[i].time < entries[j].time
+        }
+        return entries[i].kind == callEntry && entries[j].kind == returnEntry
+    })
+    return entries
+}
+
+func makeLinkedList(entries []entry) *node {
+    head := &node{id: -1}
+    calls := map[int]*node{}
+    last := head
+    for _, e := range entries {
+        n := &node{value: e.value, id: e.id, prev: last}
+        last.next = n
+        last = n
+        if e.kind == callEntry {
+            calls[e.id] = n
+        } else {
+            calls[e.id].match = n
+        }
+    }
+    return head
+}
+
+func lift(entry *node) {
+    entry.prev.next = entry.next
+    entry.next.prev = entry.prev
+    match := entry.match
+    match.prev.next = match.next
+    if match.next != nil {
+        match.next.prev = match.prev
+    }
+}
+
+func unlift(entry *node) {
+    match := entry.match
+    match.prev.next = match
+    if match.next != nil {
+        match.next.prev = match
+    }
+    entry.prev.next = entry
+    entry.next.prev = entry
+}
+
+type bitset []uint64
+
+func newBitset(bits int) bitset {
+    return make(bitset, (bits+63)/64)
+}
+
+func (b bitset) clone() bitset {
+    return append(bitset{}, b...)
+}
+
+func (b bitset) set(pos int) bitset {
+    b[pos/64] |= 1 << (uint(pos) % 64)
+    return b
+}
+
+func (b bitset) clear(pos int) bitset {
+    b[pos/64] &^= 1 << (uint(pos) % 64)
+    return b
+}
+
+func (b bitset) equals(other bitset) bool {
+    for i := range b {
+        if b[i] != other[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func (b bitset) hash() uint64 {
+    hash := uint64(14695981039346656037) // FNV-1a
+    for _, v := range b {
+        hash ^= v
+        hash *= 1099511628211
+    }
+    return hash
+}
+
+type cacheEntry struct {
+    linearized bitset
+    state      interface{}
+}
+
+func cacheContains(model Model, cache map[uint64][]cacheEntry, e cacheEntry) bool {
+    for _, c := range cache[e.linearized.hash()] {
+        if c.linearized.equals(e.linearized) && model.Equal(c.state, e.state) {
+            return true
+        }
+    }
+    return false
+}
+
+type callsEntry struct {
+    entry *node
+    state interface{}
+}
+
+func checkSingle(model Model, history []Operation, kill *int32) (ok bool, info partitionInfo) {
+    info.operations = history
+    info.stuck = -1
+
+    head := makeLinkedList(makeEntries(history))
+    linearized := newBitset(len(history))
+    cache := map[uint64][]cacheEntry{}
+    calls := []callsEntry{}
+    state := model.Init()
+
+    entry := head.next
+    for head.next != nil {
+        if atomic.LoadInt32(kill) != 0 {
+            return false, info
+        }
+        if entry.match != nil {
+            // a call: try to linearize it here.
+            matching := entry.match
+            ok, newState := model.Step(state, entry.value, matching.value)
+            if ok {
+                newLinearized := linearized.clone().set(entry.id)
+                newCacheEntry := cacheEntry{newLinearized, newState}
+                if !cacheContains(model, cache, newCacheEntry) {
+                    hash := newLinearized.hash()
+                    cache[hash] = append(cache[hash], newCacheEntry)
+                    calls = append(calls, callsEntry{entry, state})
+                    state = newState
+                    linearized.set(entry.id)
+                    lift(entry)
+                    entry = head.next
+                } else {
+                    entry = entry.next
+                }
+            } else {
+                entry = entry.next
+            }
+        } else {
+            // a return of an operation not yet linearized: backtrack.
+            if len(calls) >= len(info.longest) {
+                info.longest = linearizationOf(calls)
+                info.stuck = entry.id
+            }
+            if len(calls) == 0 {
+                return false, info
+            }
+            top := calls[len(calls)-1]
+            entry = top.entry
+            state = top.state
+            linearized.clear(entry.id)
+            calls = calls[:len(calls)-1]
+            unlift(entry)
+            entry = entry.next
+        }
+    }
+
+    info.ok = true
+    info.longest = linearizationOf(calls)
+    info.stuck = -1
+    return true, info
+}
+
+func linearizationOf(calls []callsEntry) []int {
+    ids := make([]int, len(calls))
+    for i, c := range calls {
+        ids[i] = c.entry.id
+    }
+    return ids
+}
+
+func checkParallel(model Model, partitions [][]Operation, verbose bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+    info := LinearizationInfo{model: model, partitions: make([]partitionInfo, len(partitions))}
+
+    var kill int32
+    var timedOut int32
+    if timeout > 0 {
+        timer := time.AfterFunc(timeout, func() {
+            atomic.StoreInt32(&timedOut, 1)
+            atomic.StoreInt32(&kill, 1)
+        })
+        defer timer.Stop()
+    }
+
+    var wg sync.WaitGroup
+    var illegal int32
+    for i, partition := range partitions {
+        wg.Add(1)
+        go func(i int, partition []Operation) {
+            defer wg.Done()
+            ok, partitionInfo := checkSingle(model, partition, &kill)
+            info.partitions[i] = partitionInfo
+            if !ok && atomic.LoadInt32(&timedOut) == 0 {
+                atomic.StoreInt32(&illegal, 1)
+                if !verbose {
+                    // one counterexample is enough.
+                    atomic.StoreInt32(&kill, 1)
+                }
+            }
+        }(i, partition)
+    }
+    wg.Wait()
+
+    switch {
+    case atomic.LoadInt32(&illegal) != 0:
+        return Illegal, info
+    case atomic.LoadInt32(&timedOut) != 0:
+        return Unknown, info
+    default:
+        return Ok, info
+    }
+}
+
+// turn a partition of events into operations, with times
+// taken from the events' positions.
+func eventsToOperations(events []Event) []Operation {
+    ops := []Operation{}
+    index := map[int]int{} // Id -> position in ops
+    for i, event := range events {
+        if event.Kind == CallEvent {
+            index[event.Id] = len(ops)
+            ops = append(ops, Operation{
+                ClientId: event.ClientId,
+                Input:    event.Value,
+                Call:     int64(i),
+                Return:   math.MaxInt64, // pending, unless a return turns up
+            })
+        } else if j, ok := index[event.Id]; ok {
+            ops[j].Output = event.Value
+            ops[j].Return = int64(i)
+        }
+    }
+    return ops
+}
+
+func CheckOperations(model Model, history []Operation) bool {
+    result, _ := CheckOperationsVerbose(model, history, 0)
+    return result == Ok
+}
+
+// 0 means no timeout.
+func CheckOperationsTimeout(model Model, history []Operation, timeout time.Duration) CheckResult {
+    model = model.withDefaults()
+    result, _ := checkParallel(model, model.Partition(history), false, timeout)
+    return result
+}
+
+// like CheckOperationsTimeout, but also return what is needed
+// to visualize the result. every partition is searched to the
+// end, even after one has been found to be illegal.
+func CheckOperationsVerbose(model Model, history []Operation, timeout time.Duration) (CheckResult, LinearizationInfo) {
+    model = model.withDefaults()
+    return checkParallel(model, model.Partition(history), true, timeout)
+}
+
+func CheckEvents(model Model, history []Event) bool {
+    result, _ := CheckEventsVerbose(model, history, 0)
+    return result == Ok
+}
+
+// 0 means no timeout.
+func CheckEventsTimeout(model Model, history []Event, timeout time.Duration) CheckResult {
+    model = model.withDefaults()
+    result, _ := checkParallel(model, eventPartitions(model, history), false, timeout)
+    return result
+}
+
+func CheckEventsVerbose(model Model, history []Event, timeout time.Duration) (CheckResult, LinearizationInfo) {
+    model = model.withDefaults()
+    return checkParallel(model, eventPartitions(model, history), true, timeout)
+}
+
+func eventPartitions(model Model, history []Event) [][]Operation {
+    partitions := [][]Operation{}
+    for _, events := range model.PartitionEvent(history) {
+        partitions = append(partitions, eventsToOperations(events))
+    }
+    return partitions
+}
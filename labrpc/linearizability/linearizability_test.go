@@ -0,0 +1,226 @@
+package linearizability
+
+import (
+    "bytes"
+    "fmt"
+    "lab-rpc/labrpc"
+    "math/rand"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func put(client int, value int, call int64, ret int64) Operation {
+    return Operation{client, RegisterInput{RegisterPut, value}, call, 0, ret}
+}
+
+func get(client int, value int, call int64, ret int64) Operation {
+    return Operation{client, RegisterInput{RegisterGet, 0}, call, value, ret}
+}
+
+func TestRegister(t *testing.T) {
+    tests := []struct {
+        name    string
+        history []Operation
+        ok      bool
+    }{
+        {"empty", []Operation{}, true},
+        {"sequential", []Operation{put(0, 1, 0, 10), get(1, 1, 20, 30)}, true},
+        {"concurrent old value", []Operation{put(0, 1, 0, 10), get(1, 0, 5, 15)}, true},
+        {"concurrent new value", []Operation{put(0, 1, 0, 10), get(1, 1, 5, 15)}, true},
+        {"stale read", []Operation{put(0, 1, 0, 10), get(1, 0, 20, 30)}, false},
+        {"value from nowhere", []Operation{get(0, 7, 0, 10)}, false},
+        {"reads go backwards", []Operation{
+            put(0, 1, 0, 100), get(1, 1, 10, 20), get(2, 0, 30, 40),
+        }, false},
+        {"touching is concurrent", []Operation{put(0, 1, 0, 10), get(1, 0, 10, 20)}, true},
+    }
+
+    for _, test := range tests {
+        if ok := CheckOperations(RegisterModel, test.history); ok != test.ok {
+            t.Errorf("%v: expected %v, got %v", test.name, test.ok, ok)
+        }
+    }
+}
+
+func TestKv(t *testing.T) {
+    in := func(op KvOp, key, value string) KvInput { return KvInput{op, key, value} }
+    out := func(value string) KvOutput { return KvOutput{value} }
+
+    ok := []Operation{
+        {0, in(KvPut, "x", "a"), 0, out(""), 10},
+        {1, in(KvAppend, "x", "b"), 5, out(""), 15},
+        {2, in(KvGet, "x", ""), 20, out("ab"), 30},
+        {0, in(KvPut, "y", "1"), 0, out(""), 100},
+        {1, in(KvGet, "y", ""), 20, out(""), 30},
+    }
+    if !CheckOperations(KvModel, ok) {
+        t.Fatalf("expected linearizable history")
+    }
+
+    bad := append([]Operation{}, ok...)
+    bad = append(bad, Operation{2, in(KvGet, "x", ""), 40, out("ba"), 50})
+    if CheckOperations(KvModel, bad) {
+        t.Fatalf("expected non-linearizable history")
+    }
+    if result := CheckOperationsTimeout(KvModel, bad, time.Second); result != Illegal {
+        t.Fatalf("expected Illegal, got %v", result)
+    }
+}
+
+func TestEvents(t *testing.T) {
+    events := []Event{
+        {0, CallEvent, RegisterInput{RegisterPut, 1}, 0},
+        {1, CallEvent, RegisterInput{RegisterGet, 0}, 1},
+        {1, ReturnEvent, 1, 1},
+        {0, ReturnEvent, 0, 0},
+        {2, CallEvent, RegisterInput{RegisterGet, 0}, 2},
+        {2, ReturnEvent, 1, 2},
+    }
+    if !CheckEvents(RegisterModel, events) {
+        t.Fatalf("expected linearizable events")
+    }
+
+    events = append(events,
+        Event{3, CallEvent, RegisterInput{RegisterGet, 0}, 3},
+        Event{3, ReturnEvent, 0, 3},
+    )
+    if CheckEvents(RegisterModel, events) {
+        t.Fatalf("expected non-linearizable events")
+    }
+}
+
+func TestPendingOperation(t *testing.T) {
+    // client 0 crashed during its put, which may or may not have
+    // taken effect.
+    events := []Event{
+        {0, CallEvent, RegisterInput{RegisterPut, 1}, 0},
+        {1, CallEvent, RegisterInput{RegisterGet, 0}, 1},
+        {1, ReturnEvent, 1, 1},
+        {2, CallEvent, RegisterInput{RegisterGet, 0}, 2},
+        {2, ReturnEvent, 1, 2},
+    }
+    if !CheckEvents(RegisterModel, events) {
+        t.Fatalf("expected pending put to be linearizable")
+    }
+}
+
+func TestVisualize(t *testing.T) {
+    history := []Operation{
+        put(0, 1, 0, 10),
+        put(1, 2, 20, 30),
+        get(2, 2, 25, 35),
+        get(0, 1, 40, 50),
+        get(1, 2, 45, 55),
+    }
+    result, info := CheckOperationsVerbose(RegisterModel, history, 0)
+    if result != Illegal {
+        t.Fatalf("expected Illegal, got %v", result)
+    }
+
+    var text bytes.Buffer
+    if err := WriteText(&text, info); err != nil {
+        t.Fatalf("WriteText: %v", err)
+    }
+    for _, want := range []string{"not linearizable", "cannot be linearized next", "get() -> 1"} {
+        if !strings.Contains(text.String(), want) {
+            t.Fatalf("text lacks %q:\n%s", want, text.String())
+        }
+    }
+
+    var html bytes.Buffer
+    if err := Visualize(&html, info); err != nil {
+        t.Fatalf("Visualize: %v", err)
+    }
+    for _, want := range []string{"not linearizable", `class="op stuck"`, "client 2"} {
+        if !strings.Contains(html.String(), want) {
+            t.Fatalf("html lacks %q", want)
+        }
+    }
+
+    // nothing to say about a linearizable history.
+    _, info = CheckOperationsVerbose(RegisterModel, history[:3], 0)
+    text.Reset()
+    WriteText(&text, info)
+    if text.Len() != 0 {
+        t.Fatalf("expected no text for a linearizable history, got:\n%s", text.String())
+    }
+}
+
+//
+// a history recorded from concurrent clients of a
+// key/value server over a labrpc Network.
+//
+
+type KVServer struct {
+    mu   sync.Mutex
+    data map[string]string
+}
+
+type KVArgs struct {
+    Op    KvOp
+    Key   string
+    Value string
+}
+
+func (kv *KVServer) Do(args KVArgs, reply *string) {
+    kv.mu.Lock()
+    defer kv.mu.Unlock()
+    switch args.Op {
+    case KvPut:
+        kv.data[args.Key] = args.Value
+    case KvAppend:
+        kv.data[args.Key] += args.Value
+    }
+    *reply = kv.data[args.Key]
+}
+
+func TestLabrpcHistory(t *testing.T) {
+    network := labrpc.MakeNetwork()
+    defer network.Cleanup()
+
+    server := labrpc.MakeServer()
+    server.AddService(labrpc.MakeService(&KVServer{data: map[string]string{}}))
+    network.AddServer("kv", server)
+
+    var mu sync.Mutex
+    history := []Operation{}
+    start := time.Now()
+
+    var wg sync.WaitGroup
+    for client := 0; client < 5; client++ {
+        endName := fmt.Sprintf("client-%d", client)
+        clientEnd := network.MakeEnd(endName)
+        network.Connect(endName, "kv")
+        network.Enable(endName, true)
+
+        wg.Add(1)
+        go func(client int) {
+            defer wg.Done()
+            for i := 0; i < 20; i++ {
+                args := KVArgs{KvOp(rand.Intn(3)), fmt.Sprint(rand.Intn(2)), fmt.Sprintf("%d.%d ", client, i)}
+                call := time.Since(start).Nanoseconds()
+                var reply string
+                clientEnd.Call("KVServer.Do", args, &reply)
+                ret := time.Since(start).Nanoseconds()
+
+                op := Operation{client, KvInput{args.Op, args.Key, args.Value}, call, KvOutput{}, ret}
+                if args.Op == KvGet {
+                    op.Output = KvOutput{reply}
+                }
+                mu.Lock()
+                history = append(history, op)
+                mu.Unlock()
+            }
+        }(client)
+    }
+    wg.Wait()
+
+    if result := CheckOperationsTimeout(KvModel, history, 10*time.Second); result != Ok {
+        _, info := CheckOperationsVerbose(KvModel, history, 10*time.Second)
+        var text bytes.Buffer
+        WriteText(&text, info)
+        t.Fatalf("expected linearizable history, got %v:\n%s", result, text.String())
+    }
+}
@@ -0,0 +1,247 @@
+package linearizability
+
+//
+// a checker for linearizability of concurrent histories, in the
+// style of Porcupine. a KV test records when each client operation
+// was invoked and when it returned, e.g. around ClientEnd.Call():
+//
+//    start := time.Now().UnixNano()
+//    value := ck.Get(key)
+//    end := time.Now().UnixNano()
+//    history = append(history, linearizability.Operation{
+//        ClientId: id,
+//        Input:    linearizability.KvInput{Op: linearizability.KvGet, Key: key},
+//        Output:   linearizability.KvOutput{Value: value},
+//        Call:     start,
+//        Return:   end,
+//    })
+//
+// and asks whether some sequential order of the operations, in which
+// each takes effect between its Call and Return, is legal under
+// the model:
+//
+//    if !linearizability.CheckOperations(linearizability.KvModel, history) { ... }
+//
+
+import (
+    "fmt"
+)
+
+// a sequential specification of a data type.
+type Model struct {
+    // split a history into independent parts, e.g. by key, each
+    // of which is checked separately. optional.
+    Partition      func(history []Operation) [][]Operation
+    PartitionEvent func(history []Event) [][]Event
+    // initial state of the system.
+    Init func() interface{}
+    // whether an operation with input and output is legal in
+    // state, and if so, the state after it. required.
+    Step func(state interface{}, input interface{}, output interface{}) (bool, interface{})
+    // whether two states are the same. optional; defaults to ==.
+    Equal func(state1, state2 interface{}) bool
+    // for visualization. optional.
+    DescribeOperation func(input interface{}, output interface{}) string
+    DescribeState     func(state interface{}) string
+}
+
+// an operation from a single client, and when it was invoked and
+// when it returned, in any monotonic unit such as nanoseconds.
+type Operation struct {
+    ClientId int
+    Input    interface{}
+    Call     int64
+    Output   interface{}
+    Return   int64
+}
+
+type EventKind bool
+
+const (
+    CallEvent   EventKind = false
+    ReturnEvent EventKind = true
+)
+
+// a history can also be given as a sequence of invocation and
+// return events, in the order they happened. Id pairs each call
+// with its return; a call without a return is an operation that
+// was still pending when the history ended, such as one from a
+// client that crashed, and has a nil Output.
+type Event struct {
+    ClientId int
+    Kind     EventKind
+    Value    interface{} // input for a call, output for a return
+    Id       int
+}
+
+func (model Model) withDefaults() Model {
+    if model.Partition == nil {
+        model.Partition = noPartition
+    }
+    if model.PartitionEvent == nil {
+        model.PartitionEvent = noPartitionEvent
+    }
+    if model.Equal == nil {
+        model.Equal = shallowEqual
+    }
+    if model.DescribeOperation == nil {
+        model.DescribeOperation = defaultDescribeOperation
+    }
+    if model.DescribeState == nil {
+        model.DescribeState = defaultDescribeState
+    }
+    return model
+}
+
+func noPartition(history []Operation) [][]Operation {
+    return [][]Operation{history}
+}
+
+func noPartitionEvent(history []Event) [][]Event {
+    return [][]Event{history}
+}
+
+func shallowEqual(state1, state2 interface{}) bool {
+    return state1 == state2
+}
+
+func defaultDescribeOperation(input interface{}, output interface{}) string {
+    return fmt.Sprintf("%v -> %v", input, output)
+}
+
+func defaultDescribeState(state interface{}) string {
+    return fmt.Sprintf("%v", state)
+}
+
+//
+// a register holding an int, initially 0.
+//
+
+type RegisterOp int
+
+const (
+    RegisterGet RegisterOp = iota
+    RegisterPut
+)
+
+type RegisterInput struct {
+    Op    RegisterOp
+    Value int // for RegisterPut
+}
+
+// the Output of a RegisterGet is the int read; a RegisterPut's
+// is ignored. a pending RegisterGet (nil Output) may read anything.
+var RegisterModel = Model{
+    Init: func() interface{} {
+        return 0
+    },
+    Step: func(state interface{}, input interface{}, output interface{}) (bool, interface{}) {
+        in := input.(RegisterInput)
+        if in.Op == RegisterPut {
+            return true, in.Value
+        }
+        return output == nil || output.(int) == state.(int), state
+    },
+    DescribeOperation: func(input interface{}, output interface{}) string {
+        in := input.(RegisterInput)
+        if in.Op == RegisterPut {
+            return fmt.Sprintf("put(%d)", in.Value)
+        }
+        return fmt.Sprintf("get() -> %v", output)
+    },
+}
+
+//
+// a key/value store with Get, Put and Append, as in the 6.824
+// KV labs. every key is initially "".
+//
+
+type KvOp int
+
+const (
+    KvGet KvOp = iota
+    KvPut
+    KvAppend
+)
+
+type KvInput struct {
+    Op    KvOp
+    Key   string
+    Value string // for KvPut and KvAppend
+}
+
+type KvOutput struct {
+    Value string // for KvGet
+}
+
+// partitioned by key, so each key's operations are checked as a
+// register of their own. a pending KvGet (nil Output) may read anything.
+var KvModel = Model{
+    Partition: func(history []Operation) [][]Operation {
+        byKey := map[string][]Operation{}
+        keys := []string{}
+        for _, op := range history {
+            key := op.Input.(KvInput).Key
+            if _, ok := byKey[key]; !ok {
+                keys = append(keys, key)
+            }
+            byKey[key] = append(byKey[key], op)
+        }
+        partitions := [][]Operation{}
+        for _, key := range keys {
+            partitions = append(partitions, byKey[key])
+        }
+        return partitions
+    },
+    PartitionEvent: func(history []Event) [][]Event {
+        byKey := map[string][]Event{}
+        keyOf := map[int]string{} // Id -> key, since returns carry no input
+        keys := []string{}
+        for _, event := range history {
+            if event.Kind == CallEvent {
+                keyOf[event.Id] = event.Value.(KvInput).Key
+            }
+            key := keyOf[event.Id]
+            if _, ok := byKey[key]; !ok {
+                keys = append(keys, key)
+            }
+            byKey[key] = append(byKey[key], event)
+        }
+        partitions := [][]Event{}
+        for _, key := range keys {
+            partitions = append(partitions, byKey[key])
+        }
+        return partitions
+    },
+    Init: func() interface{} {
+        return ""
+    },
+    Step: func(state interface{}, input interface{}, output interface{}) (bool, interface{}) {
+        in := input.(KvInput)
+        switch in.Op {
+        case KvPut:
+            return true, in.Value
+        case KvAppend:
+            return true, state.(string) + in.Value
+        default:
+            return output == nil || output.(KvOutput).Value == state.(string), state
+        }
+    },
+    DescribeOperation: func(input interface{}, output interface{}) string {
+        in := input.(KvInput)
+        switch in.Op {
+        case KvPut:
+            return fmt.Sprintf("put(%q, %q)", in.Key, in.Value)
+        case KvAppend:
+            return fmt.Sprintf("append(%q, %q)", in.Key, in.Value)
+        default:
+            if output == nil {
+                return fmt.Sprintf("get(%q) -> ?", in.Key)
+            }
+            return fmt.Sprintf("get(%q) -> %q", in.Key, output.(KvOutput).Value)
+        }
+    },
+    DescribeState: func(state interface{}) string {
+        return fmt.Sprintf("%q", state)
+    },
+}
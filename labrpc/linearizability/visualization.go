@@ -0,0 +1,233 @@
+package linearizability
+
+//
+// show where a history stops being linearizable: the longest prefix
+// the checker managed to linearize, the operation it could not fit in
+// after that prefix, and the operations concurrent with it, which
+// together make up the non-linearizable region.
+//
+
+import (
+    "fmt"
+    "html/template"
+    "io"
+    "math"
+    "sort"
+)
+
+type opStatus string
+
+const (
+    statusLinearized opStatus = "linearized" // in the longest linearizable prefix
+    statusStuck      opStatus = "stuck"      // could not be linearized after that prefix
+    statusRegion     opStatus = "region"     // concurrent with the stuck operation
+    statusOther      opStatus = "other"
+)
+
+type describedOp struct {
+    Id          int
+    ClientId    int
+    Call        int64
+    Return      int64 // math.MaxInt64 if pending
+    Description string
+    Status      opStatus
+    Step        int    // 1-based position in the linearization, or 0
+    StateAfter  string // for linearized operations
+}
+
+type describedPartition struct {
+    Index    int
+    Ok       bool
+    Ops      []describedOp // in order of Call
+    Longest  []describedOp // in linearization order
+    Stuck    *describedOp
+    Region   []describedOp
+    MinTime  int64
+    MaxTime  int64
+    Clients  []int
+    Timeline map[int][]timelineOp // ClientId -> ops
+}
+
+type timelineOp struct {
+    describedOp
+    Left  float64 // percent
+    Width float64 // percent
+}
+
+func (info LinearizationInfo) describe() []describedPartition {
+    model := info.model
+    described := []describedPartition{}
+    for index, partition := range info.partitions {
+        p := describedPartition{Index: index, Ok: partition.ok}
+
+        ops := make([]describedOp, len(partition.operations))
+        for id, op := range partition.operations {
+            ops[id] = describedOp{
+                Id:          id,
+                ClientId:    op.ClientId,
+                Call:        op.Call,
+                Return:      op.Return,
+                Description: model.DescribeOperation(op.Input, op.Output),
+                Status:      statusOther,
+            }
+        }
+
+        state := model.Init()
+        for step, id := range partition.longest {
+            op := partition.operations[id]
+            _, state = model.Step(state, op.Input, op.Output)
+            ops[id].Status = statusLinearized
+            ops[id].Step = step + 1
+            ops[id].StateAfter = model.DescribeState(state)
+        }
+
+        if partition.stuck >= 0 {
+            stuck := partition.operations[partition.stuck]
+            ops[partition.stuck].Status = statusStuck
+            for id, op := range partition.operations {
+                if ops[id].Status == statusOther && op.Call <= stuck.Return && stuck.Call <= op.Return {
+                    ops[id].Status = statusRegion
+                }
+            }
+        }
+
+        for _, id := range partition.longest {
+            p.Longest = append(p.Longest, ops[id])
+        }
+        if partition.stuck >= 0 {
+            stuck := ops[partition.stuck]
+            p.Stuck = &stuck
+        }
+        p.Ops = append(p.Ops, ops...)
+        sort.SliceStable(p.Ops, func(i, j int) bool { return p.Ops[i].Call < p.Ops[j].Call })
+        for _, op := range p.Ops {
+            if op.Status == statusRegion {
+                p.Region = append(p.Region, op)
+            }
+        }
+
+        p.layout()
+        described = append(described, p)
+    }
+    return described
+}
+
+// place each operation on a per-client timeline, in percent of
+// the partition's time span. pending operations run to the end.
+func (p *describedPartition) layout() {
+    p.MinTime = math.MaxInt64
+    p.MaxTime = math.MinInt64
+    for _, op := range p.Ops {
+        if op.Call < p.MinTime {
+            p.MinTime = op.Call
+        }
+        if op.Return != math.MaxInt64 && op.Return > p.MaxTime {
+            p.MaxTime = op.Return
+        }
+        if op.Call > p.MaxTime {
+            p.MaxTime = op.Call
+        }
+    }
+    span := float64(p.MaxTime - p.MinTime)
+    if span <= 0 {
+        span = 1
+    }
+
+    p.Timeline = map[int][]timelineOp{}
+    for _, op := range p.Ops {
+        end := op.Return
+        if end > p.MaxTime {
+            end = p.MaxTime
+        }
+        if _, ok := p.Timeline[op.ClientId]; !ok {
+            p.Clients = append(p.Clients, op.ClientId)
+        }
+        p.Timeline[op.ClientId] = append(p.Timeline[op.ClientId], timelineOp{
+            describedOp: op,
+            Left:        100 * float64(op.Call-p.MinTime) / span,
+            Width:       math.Max(0.5, 100*float64(end-op.Call)/span),
+        })
+    }
+    sort.Ints(p.Clients)
+}
+
+func (op describedOp) String() string {
+    ret := fmt.Sprint(op.Return)
+    if op.Return == math.MaxInt64 {
+        ret = "pending"
+    }
+    return fmt.Sprintf("[client %d] %s  (call %d, return %s)", op.ClientId, op.Description, op.Call, ret)
+}
+
+// write a plain-text account of each partition that is not
+// linearizable; nothing is written for one that is.
+func WriteText(w io.Writer, info LinearizationInfo) error {
+    for _, p := range info.describe() {
+        if p.Ok {
+            continue
+        }
+        fmt.Fprintf(w, "partition %d: not linearizable\n", p.Index)
+        fmt.Fprintf(w, "  longest linearizable prefix, %d of %d operations:\n", len(p.Longest), len(p.Ops))
+        for _, op := range p.Longest {
+            fmt.Fprintf(w, "    %3d. %v -> state %s\n", op.Step, op, op.StateAfter)
+        }
+        if p.Stuck != nil {
+            fmt.Fprintf(w, "  cannot be linearized next:\n")
+            fmt.Fprintf(w, "         %v\n", *p.Stuck)
+        }
+        if len(p.Region) > 0 {
+            fmt.Fprintf(w, "  concurrent with it, and not linearized either:\n")
+            for _, op := range p.Region {
+                fmt.Fprintf(w, "         %v\n", op)
+            }
+        }
+        if _, err := fmt.Fprintln(w); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+var visualizationTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>linearizability</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+.row { position: relative; height: 24px; margin: 2px 0 2px 80px; background: #f4f4f4; }
+.label { position: absolute; left: -80px; width: 75px; text-align: right; line-height: 24px; }
+.op { position: absolute; top: 2px; height: 20px; overflow: hidden; white-space: nowrap;
+      font-size: 11px; line-height: 20px; padding: 0 2px; box-sizing: border-box; border-radius: 3px; }
+.linearized { background: #b7e1b0; }
+.stuck { background: #f28b82; font-weight: bold; }
+.region { background: #fdd663; }
+.other { background: #d0d0d0; }
+</style>
+</head>
+<body>
+{{range .}}
+<h2>partition {{.Index}}: {{if .Ok}}linearizable{{else}}not linearizable{{end}}</h2>
+{{if not .Ok}}<p>
+<span class="op linearized" style="position: static">longest linearizable prefix</span>
+<span class="op stuck" style="position: static">cannot be linearized next</span>
+<span class="op region" style="position: static">concurrent, not linearized</span>
+</p>{{end}}
+{{$p := .}}{{range .Clients}}
+<div class="row"><div class="label">client {{.}}</div>
+{{range index $p.Timeline .}}<div class="op {{.Status}}" style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%"
+  title="{{.Description}}{{if .Step}} (step {{.Step}}, state after: {{.StateAfter}}){{end}}">{{.Description}}</div>
+{{end}}</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// write an HTML page with a timeline of every partition's
+// operations, per client, colored to show the non-linearizable
+// region. hovering over an operation shows its linearization
+// step and the state after it.
+func Visualize(w io.Writer, info LinearizationInfo) error {
+    return visualizationTemplate.Execute(w, info.describe())
+}